@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,11 +29,276 @@ func JoinBBB(c *gin.Context) {
 		return
 	}
 
-	err := services.ProcessBroadcasterRequest(&request)
+	session, err := services.StartBroadcasterSession(&request)
+	if errors.Is(err, services.ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Broadcasting session started successfully"})
+	response := gin.H{"message": "Broadcasting session started successfully", "session_id": session.ID}
+	if companions := session.Companions(); len(companions) > 0 {
+		response["companion_session_ids"] = companions
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// StartTestBroadcast godoc
+// @Summary      Start test broadcast
+// @Description  Streams a built-in test page (colour bars, clock and tone) to the destinations for a short time instead of a meeting, to check stream keys ahead of an event
+// @Tags         Broadcaster
+// @Accept       json
+// @Produce      json
+// @Param        request body models.TestBroadcastRequest true "Test Broadcast Request"
+// @Success      200 {object} models.BroadcasterResponse
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /broadcaster/test [post]
+func StartTestBroadcast(c *gin.Context) {
+	var request models.TestBroadcastRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := services.StartTestBroadcast(&request)
+	if errors.Is(err, services.ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "Test broadcast started successfully", "session_id": session.ID}
+	if companions := session.Companions(); len(companions) > 0 {
+		response["companion_session_ids"] = companions
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ValidateBroadcast godoc
+// @Summary      Validate broadcast
+// @Description  Runs the checks of a real start without launching a browser and reports the outcome of each: BBB server, join link checksum, meeting, health check URL, destinations and the Moon hub
+// @Tags         Broadcaster
+// @Accept       json
+// @Produce      json
+// @Param        request body models.BroadcasterRequest true "Broadcaster Request"
+// @Success      200 {object} models.PreflightReport
+// @Failure      400 {object} models.ErrorResponse
+// @Router       /broadcaster/validate [post]
+func ValidateBroadcast(c *gin.Context) {
+	var request models.BroadcasterRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.ValidateBroadcasterRequest(&request))
+}
+
+// GetSession godoc
+// @Summary      Get broadcaster session
+// @Description  Returns the state, events and captured browser logs of a broadcaster session
+// @Tags         Broadcaster
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} models.BroadcasterSession
+// @Failure      404 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id} [get]
+func GetSession(c *gin.Context) {
+	session, ok := services.GetSession(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrSessionNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session.Snapshot())
+}
+
+// ListRecordings godoc
+// @Summary      List recordings
+// @Description  Lists the recordings of a broadcaster session started with record, with their size and duration
+// @Tags         Broadcaster
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {array} models.Recording
+// @Failure      404 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id}/recordings [get]
+func ListRecordings(c *gin.Context) {
+	session, ok := services.GetSession(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrSessionNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session.Recordings())
+}
+
+// DownloadRecording godoc
+// @Summary      Download recording
+// @Description  Downloads a recording of a broadcaster session
+// @Tags         Broadcaster
+// @Produce      octet-stream
+// @Param        id path string true "Session ID"
+// @Param        name path string true "Recording name"
+// @Success      200 {file} file
+// @Failure      404 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id}/recordings/{name} [get]
+func DownloadRecording(c *gin.Context) {
+	session, ok := services.GetSession(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrSessionNotFound.Error()})
+		return
+	}
+
+	path, err := session.RecordingFile(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.FileAttachment(path, c.Param("name"))
+}
+
+// UpdateOverlay godoc
+// @Summary      Update overlay
+// @Description  Changes a branding overlay of a broadcaster session, such as the lower-third text, while it is live
+// @Tags         Broadcaster
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        overlay_id path string true "Overlay ID"
+// @Param        request body models.OverlayUpdate true "Overlay Update"
+// @Success      200 {object} models.Overlay
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id}/overlays/{overlay_id} [patch]
+func UpdateOverlay(c *gin.Context) {
+	var update models.OverlayUpdate
+
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	overlay, err := services.UpdateSessionOverlay(c.Param("id"), c.Param("overlay_id"), update)
+	if errors.Is(err, services.ErrSessionNotFound) || errors.Is(err, services.ErrOverlayNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, overlay)
+}
+
+// ApplyLayoutAction godoc
+// @Summary      Change layout
+// @Description  Runs a layout action against the live browser of a broadcaster session. show_chat and show_user_list are rejected with 409 while the clean feed is on
+// @Tags         Broadcaster
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        request body models.LayoutActionRequest true "Layout Action"
+// @Success      200 {object} models.BroadcasterResponse
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id}/layout [post]
+func ApplyLayoutAction(c *gin.Context) {
+	var request models.LayoutActionRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.ApplyLayoutAction(c.Param("id"), request.Action)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrSessionNotLive) || errors.Is(err, services.ErrHiddenByCleanFeed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Layout action applied successfully"})
+}
+
+// ApplyDelayAction godoc
+// @Summary      Dump or resume the delay
+// @Description  Dumps the delay buffer of a broadcaster session started with delay_seconds and puts its slate on air, or takes the slate down and brings the delay back
+// @Tags         Broadcaster
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        request body models.DelayActionRequest true "Delay Action"
+// @Success      200 {object} models.BroadcasterResponse
+// @Failure      400 {object} models.ErrorResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Failure      500 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id}/delay [post]
+func ApplyDelayAction(c *gin.Context) {
+	var request models.DelayActionRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.ApplyDelayAction(c.Param("id"), request.Action)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrSessionNotLive) || errors.Is(err, services.ErrNoDelay) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Delay action applied successfully"})
+}
+
+// RestartSession godoc
+// @Summary      Restart the bot browser
+// @Description  Starts a replacement browser for a live broadcaster session and switches the stream to it once it has joined, without the destinations going offline. Needs the RTMP relay for every destination
+// @Tags         Broadcaster
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      202 {object} models.BroadcasterResponse
+// @Failure      404 {object} models.ErrorResponse
+// @Failure      409 {object} models.ErrorResponse
+// @Router       /broadcaster/sessions/{id}/restart [post]
+func RestartSession(c *gin.Context) {
+	err := services.RestartSession(c.Param("id"))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Restart started, follow the session events"})
 }
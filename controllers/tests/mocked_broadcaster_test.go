@@ -138,6 +138,34 @@ var _ = Describe("Broadcaster Controller (with mocking)", func() {
 			}
 		},
 		Entry("valid request", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123"}`, http.StatusOK, "successfully"),
+		Entry("greenlight room request", `{"greenlight_room_url":"https://gl.example.com/rooms/xyz-abc/join","access_code":"123456","display_name":"Live Stream","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123"}`, http.StatusOK, "successfully"),
+		Entry("missing both BBB and Greenlight URLs", `{"bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123"}`, http.StatusBadRequest, "BBBServerURL"),
+		Entry("clean layout", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","layout":"clean"}`, http.StatusOK, "successfully"),
+		Entry("unknown layout", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","layout":"fancy"}`, http.StatusBadRequest, "Layout"),
+		Entry("branding overlays", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","overlays":[{"id":"logo","type":"logo","image_url":"https://example.com/logo.png"},{"id":"title","type":"lower_third","text":"Lecture 1","position":"bottom-left"},{"id":"live","type":"live_badge"}]}`, http.StatusOK, "successfully"),
+		Entry("logo overlay without image", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","overlays":[{"id":"logo","type":"logo"}]}`, http.StatusBadRequest, "ImageURL"),
+		Entry("auto-director rules", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","auto_director":{"enabled":true,"rules":[{"on":"screenshare_started","action":"presentation_fullscreen"},{"on":"screenshare_stopped","action":"smart_layout"}]}}`, http.StatusOK, "successfully"),
+		Entry("auto-director rule with unknown event", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","auto_director":{"enabled":true,"rules":[{"on":"coffee_break","action":"smart_layout"}]}}`, http.StatusBadRequest, "On"),
+		Entry("multiple destinations", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","destinations":[{"url":"rtmp://a.rtmp.youtube.com/live2","key":"abcd-efgh-ijkl-mnop","label":"youtube"},{"url":"rtmp://live.twitch.tv/app","key":"live_123456789_AbCdEf","label":"twitch"}]}`, http.StatusOK, "successfully"),
+		Entry("destination without label", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","destinations":[{"url":"rtmp://a.rtmp.youtube.com/live2","key":"abcd-efgh-ijkl-mnop"}]}`, http.StatusBadRequest, "Label"),
+		Entry("platform preset without rtmp_url", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","platform":"youtube","stream_key":"abcd-efgh-ijkl-mnop"}`, http.StatusOK, "successfully"),
+		Entry("unknown platform", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","platform":"myspace","stream_key":"abcd"}`, http.StatusBadRequest, "Platform"),
+		Entry("SRT destination with options", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","destinations":[{"url":"srt://ingest.example.com:9000","key":"event-42","label":"cdn","srt":{"passphrase":"0123456789abcdef","latency_ms":200}},{"url":"rtmps://live-api-s.facebook.com:443/rtmp","key":"FB-1234567890-0-AbCdEf","label":"facebook"}]}`, http.StatusOK, "successfully"),
+		Entry("unsupported output protocol", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"ftp://streaming.example.com/live","stream_key":"stream-123"}`, http.StatusBadRequest, "RTMPURL"),
+		Entry("audio only to an Icecast mount", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","quality":"audio_only","audio":{"codec":"mp3","bitrate_kbps":96},"destinations":[{"url":"icecast://radio.example.com:8000/live.mp3","key":"hackme","label":"radio"}]}`, http.StatusOK, "successfully"),
+		Entry("unknown audio codec", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://radio.example.com/live","stream_key":"stream-123","audio":{"codec":"flac"}}`, http.StatusBadRequest, "Codec"),
+		Entry("SRT passphrase too short", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"srt://ingest.example.com:9000","stream_key":"event-42","srt":{"passphrase":"short"}}`, http.StatusBadRequest, "Passphrase"),
+		Entry("WHIP destination only", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","whip_destinations":[{"endpoint_url":"https://whip.example.com/whip/endpoint","bearer_token":"secret","label":"partner"}]}`, http.StatusOK, "successfully"),
+		Entry("WHIP destination without endpoint", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","whip_destinations":[{"label":"partner"}]}`, http.StatusBadRequest, "EndpointURL"),
+		Entry("VNC and video for debugging", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","enable_vnc":true,"enable_video":true}`, http.StatusOK, "successfully"),
+		Entry("delay buffer", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","delay_seconds":20,"dump_slate":{"text":"Back shortly"}}`, http.StatusOK, "successfully"),
+		Entry("delay too long", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","delay_seconds":120}`, http.StatusBadRequest, "DelaySeconds"),
+		Entry("starting and ended slates", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","starting_slate":{"text":"Lecture starts at 2pm","image_url":"https://cdn.example.com/logo.png","countdown_to":"2024-05-01T14:00:00Z"},"ended_slate":{"html":"<h1>Thanks for watching</h1>"},"ended_slate_seconds":60}`, http.StatusOK, "successfully"),
+		Entry("ended slate without a hold", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","ended_slate":{"text":"Thanks for watching"},"ended_slate_seconds":0}`, http.StatusOK, "successfully"),
+		Entry("negative ended slate hold", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","ended_slate":{"text":"Thanks for watching"},"ended_slate_seconds":-1}`, http.StatusBadRequest, "EndedSlateSeconds"),
+		Entry("slate with invalid page URL", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","starting_slate":{"page_url":"not a url"}}`, http.StatusBadRequest, "PageURL"),
+		Entry("fallback video", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","fallback":{"text":"Technical difficulties, back shortly","video_url":"https://cdn.example.com/loop.mp4"}}`, http.StatusOK, "successfully"),
+		Entry("fallback with invalid video URL", `{"bbb_server_url":"https://example.com/bigbluebutton","bbb_health_check_url":"https://example.com/bigbluebutton/api/health","rtmp_url":"rtmp://live.twitch.tv/app","stream_key":"live_123456789_AbCdEf","fallback":{"video_url":"not a url"}}`, http.StatusBadRequest, "VideoURL"),
 		Entry("empty request", `{}`, http.StatusBadRequest, "error"),
 		Entry("invalid JSON", `{"invalid": json}`, http.StatusBadRequest, "error"),
 		Entry("malformed JSON", `{invalid}`, http.StatusBadRequest, "error"),
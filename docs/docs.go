@@ -70,9 +70,44 @@ const docTemplate = `{
                 }
             }
         },
-        "/health": {
+        "/broadcaster/sessions/{id}": {
             "get": {
-                "description": "Returns the health status of the application",
+                "description": "Returns the state, events and captured browser logs of a broadcaster session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "Get broadcaster session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BroadcasterSession"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/broadcaster/sessions/{id}/delay": {
+            "post": {
+                "description": "Dumps the delay buffer of a broadcaster session started with delay_seconds and puts its slate on air, or takes the slate down and brings the delay back",
                 "consumes": [
                     "application/json"
                 ],
@@ -80,25 +115,64 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Health"
+                    "Broadcaster"
+                ],
+                "summary": "Dump or resume the delay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Delay Action",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DelayActionRequest"
+                        }
+                    }
                 ],
-                "summary": "Health Check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.BroadcasterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/readiness": {
-            "get": {
-                "description": "Checks if the application is ready to handle traffic",
+        "/broadcaster/sessions/{id}/layout": {
+            "post": {
+                "description": "Runs a layout action against the live browser of a broadcaster session. show_chat and show_user_list are rejected with 409 while the clean feed is on",
                 "consumes": [
                     "application/json"
                 ],
@@ -106,60 +180,1212 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Health"
+                    "Broadcaster"
+                ],
+                "summary": "Change layout",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Layout Action",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LayoutActionRequest"
+                        }
+                    }
                 ],
-                "summary": "Readiness Check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "$ref": "#/definitions/models.BroadcasterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/broadcaster/sessions/{id}/overlays/{overlay_id}": {
+            "patch": {
+                "description": "Changes a branding overlay of a broadcaster session, such as the lower-third text, while it is live",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "Update overlay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Overlay ID",
+                        "name": "overlay_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Overlay Update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.OverlayUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Overlay"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/broadcaster/sessions/{id}/recordings": {
+            "get": {
+                "description": "Lists the recordings of a broadcaster session started with record, with their size and duration",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "List recordings",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Recording"
                             }
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.BroadcasterRequest": {
-            "type": "object",
-            "required": [
-                "bbb_health_check_url",
-                "bbb_server_url",
-                "rtmp_url",
-                "stream_key"
-            ],
-            "properties": {
-                "bbb_health_check_url": {
-                    "type": "string"
-                },
-                "bbb_server_url": {
-                    "type": "string"
-                },
-                "rtmp_url": {
-                    "type": "string"
-                },
-                "stream_key": {
-                    "type": "string"
+        },
+        "/broadcaster/sessions/{id}/recordings/{name}": {
+            "get": {
+                "description": "Downloads a recording of a broadcaster session",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "Download recording",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recording name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "models.BroadcasterResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
+        "/broadcaster/sessions/{id}/restart": {
+            "post": {
+                "description": "Starts a replacement browser for a live broadcaster session and switches the stream to it once it has joined, without the destinations going offline. Needs the RTMP relay for every destination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "Restart the bot browser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.BroadcasterResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "models.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
+        "/broadcaster/test": {
+            "post": {
+                "description": "Streams a built-in test page (colour bars, clock and tone) to the destinations for a short time instead of a meeting, to check stream keys ahead of an event",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "Start test broadcast",
+                "parameters": [
+                    {
+                        "description": "Test Broadcast Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TestBroadcastRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BroadcasterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/broadcaster/validate": {
+            "post": {
+                "description": "Runs the checks of a real start without launching a browser and reports the outcome of each: BBB server, join link checksum, meeting, health check URL, destinations and the Moon hub",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Broadcaster"
+                ],
+                "summary": "Validate broadcast",
+                "parameters": [
+                    {
+                        "description": "Broadcaster Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BroadcasterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PreflightReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Returns the health status of the application",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Health Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readiness": {
+            "get": {
+                "description": "Checks if the application is ready to handle traffic",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.AudioOptions": {
+            "type": "object",
+            "properties": {
+                "bitrate_kbps": {
+                    "type": "integer",
+                    "maximum": 320,
+                    "minimum": 32
+                },
+                "channels": {
+                    "type": "integer",
+                    "enum": [
+                        1,
+                        2
+                    ]
+                },
+                "codec": {
+                    "type": "string",
+                    "enum": [
+                        "aac",
+                        "mp3",
+                        "opus"
+                    ]
+                },
+                "sample_rate": {
+                    "type": "integer",
+                    "enum": [
+                        44100,
+                        48000
+                    ]
+                }
+            }
+        },
+        "models.AutoDirector": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "rules": {
+                    "description": "Defaults to fullscreen screenshare when one starts and smart layout when it stops",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DirectorRule"
+                    }
+                }
+            }
+        },
+        "models.BroadcasterRequest": {
+            "type": "object",
+            "required": [
+                "bbb_health_check_url"
+            ],
+            "properties": {
+                "access_code": {
+                    "type": "string"
+                },
+                "audio": {
+                    "description": "Audio encoding, on top of the quality profile",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AudioOptions"
+                        }
+                    ]
+                },
+                "auto_director": {
+                    "description": "Rule-based layout changes driven by what happens in the meeting",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AutoDirector"
+                        }
+                    ]
+                },
+                "bbb_health_check_url": {
+                    "type": "string"
+                },
+                "bbb_server_url": {
+                    "type": "string"
+                },
+                "delay_seconds": {
+                    "description": "Seconds the output is held before it reaches the destinations, so a producer can dump it; needs the RTMP relay",
+                    "type": "integer",
+                    "maximum": 30,
+                    "minimum": 10
+                },
+                "destinations": {
+                    "description": "Simulcast destinations, used instead of rtmp_url and stream_key",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Destination"
+                    }
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "dump_slate": {
+                    "description": "Shown after a dump, \"We'll be right back\" by default",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Slate"
+                        }
+                    ]
+                },
+                "enable_video": {
+                    "type": "boolean"
+                },
+                "enable_vnc": {
+                    "description": "Moon debugging: live VNC access to the bot, and a video of its screen kept for postmortems",
+                    "type": "boolean"
+                },
+                "ended_slate": {
+                    "$ref": "#/definitions/models.Slate"
+                },
+                "ended_slate_seconds": {
+                    "type": "integer",
+                    "maximum": 3600,
+                    "minimum": 0
+                },
+                "fallback": {
+                    "description": "Shown while the meeting is unreachable, e.g. a \"technical difficulties\" slate or a looping video_url",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Slate"
+                        }
+                    ]
+                },
+                "greenlight_room_url": {
+                    "description": "Greenlight room link (e.g. https://gl.example.com/rooms/xyz-abc/join), used instead of a BBB API join link",
+                    "type": "string"
+                },
+                "guest_approval_timeout_seconds": {
+                    "description": "How long to wait in the BBB guest lobby for a moderator to approve the bot",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "layout": {
+                    "description": "Layout of the captured page, \"clean\" hides the BBB interface around the meeting content",
+                    "type": "string",
+                    "enum": [
+                        "default",
+                        "clean"
+                    ]
+                },
+                "orientation": {
+                    "description": "Orientation of rtmp_url, and of the bot once destinations are split by orientation",
+                    "type": "string",
+                    "enum": [
+                        "landscape",
+                        "portrait"
+                    ]
+                },
+                "overlays": {
+                    "description": "Branding overlays, taken from the tenant profile when none are given",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Overlay"
+                    }
+                },
+                "platform": {
+                    "description": "Platform preset for stream_key, the ingest URL is built from it when rtmp_url is empty",
+                    "type": "string",
+                    "enum": [
+                        "youtube",
+                        "twitch",
+                        "facebook",
+                        "kick",
+                        "linkedin",
+                        "tiktok",
+                        "custom"
+                    ]
+                },
+                "quality": {
+                    "description": "Output quality profile, 1080p30 by default",
+                    "type": "string",
+                    "enum": [
+                        "720p30",
+                        "1080p30",
+                        "1080p60",
+                        "audio_only"
+                    ]
+                },
+                "record": {
+                    "description": "Also write the program output to a file, mp4 by default",
+                    "type": "boolean"
+                },
+                "recording_format": {
+                    "type": "string",
+                    "enum": [
+                        "mp4",
+                        "mkv"
+                    ]
+                },
+                "rtmp_url": {
+                    "type": "string"
+                },
+                "srt": {
+                    "description": "Options for an srt:// rtmp_url",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SRTOptions"
+                        }
+                    ]
+                },
+                "starting_slate": {
+                    "description": "Shown until the meeting is running, and for ended_slate_seconds (default 30, 0 for no hold) after it ends",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Slate"
+                        }
+                    ]
+                },
+                "stream_key": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "type": "string"
+                },
+                "whip_destinations": {
+                    "description": "WebRTC (WHIP) endpoints the captured tab is published to, in addition to or instead of RTMP",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WHIPDestination"
+                    }
+                }
+            }
+        },
+        "models.BroadcasterResponse": {
+            "type": "object",
+            "properties": {
+                "companion_session_ids": {
+                    "description": "Sessions of the extra bots started for destinations of another orientation",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BroadcasterSession": {
+            "type": "object",
+            "properties": {
+                "browser_logs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BrowserLogEntry"
+                    }
+                },
+                "companion_session_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "debug": {
+                    "$ref": "#/definitions/models.DebugEndpoints"
+                },
+                "delay": {
+                    "$ref": "#/definitions/models.DelayStatus"
+                },
+                "destinations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DestinationStatus"
+                    }
+                },
+                "diagnosis": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SessionEvent"
+                    }
+                },
+                "fallback_since": {
+                    "description": "Set while the fallback is on air because the meeting is unreachable",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "overlays": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Overlay"
+                    }
+                },
+                "parent_session_id": {
+                    "description": "Bots started together to feed destinations of different orientations",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.SessionState"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.SessionType"
+                }
+            }
+        },
+        "models.BrowserLogEntry": {
+            "type": "object",
+            "properties": {
+                "fatal": {
+                    "type": "boolean"
+                },
+                "level": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DebugEndpoints": {
+            "type": "object",
+            "properties": {
+                "devtools_url": {
+                    "type": "string"
+                },
+                "moon_session_id": {
+                    "type": "string"
+                },
+                "vnc_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DelayActionRequest": {
+            "type": "object",
+            "required": [
+                "action"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "dump",
+                        "resume"
+                    ]
+                }
+            }
+        },
+        "models.DelayStatus": {
+            "type": "object",
+            "properties": {
+                "dumped": {
+                    "type": "boolean"
+                },
+                "dumped_at": {
+                    "type": "string"
+                },
+                "seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Destination": {
+            "type": "object",
+            "required": [
+                "label"
+            ],
+            "properties": {
+                "backup": {
+                    "description": "Push to the platform's backup ingest instead of the primary one",
+                    "type": "boolean"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "orientation": {
+                    "description": "Portrait destinations are fed by a bot of their own",
+                    "type": "string",
+                    "enum": [
+                        "landscape",
+                        "portrait"
+                    ]
+                },
+                "platform": {
+                    "type": "string",
+                    "enum": [
+                        "youtube",
+                        "twitch",
+                        "facebook",
+                        "kick",
+                        "linkedin",
+                        "tiktok",
+                        "custom"
+                    ]
+                },
+                "srt": {
+                    "description": "Options for srt:// URLs",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SRTOptions"
+                        }
+                    ]
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DestinationStatus": {
+            "type": "object",
+            "properties": {
+                "bitrate_kbps": {
+                    "type": "number"
+                },
+                "bytes_sent": {
+                    "description": "Traffic of destinations pushed through the RTMP relay",
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "orientation": {
+                    "type": "string"
+                },
+                "platform": {
+                    "type": "string"
+                },
+                "protocol": {
+                    "type": "string"
+                },
+                "reconnects": {
+                    "type": "integer"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DirectorRule": {
+            "type": "object",
+            "required": [
+                "action",
+                "on"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "presentation_fullscreen",
+                        "focus_webcams",
+                        "smart_layout",
+                        "speaker_focus",
+                        "hide_chat",
+                        "show_chat",
+                        "hide_user_list",
+                        "show_user_list"
+                    ]
+                },
+                "on": {
+                    "type": "string",
+                    "enum": [
+                        "screenshare_started",
+                        "screenshare_stopped",
+                        "presenter_changed",
+                        "presentation_uploaded",
+                        "webcams_on",
+                        "webcams_off"
+                    ]
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LayoutActionRequest": {
+            "type": "object",
+            "required": [
+                "action"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "presentation_fullscreen",
+                        "focus_webcams",
+                        "smart_layout",
+                        "speaker_focus",
+                        "hide_chat",
+                        "show_chat",
+                        "hide_user_list",
+                        "show_user_list"
+                    ]
+                }
+            }
+        },
+        "models.Overlay": {
+            "type": "object",
+            "required": [
+                "id",
+                "type"
+            ],
+            "properties": {
+                "hidden": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "position": {
+                    "description": "One of top-left, top-right, bottom-left, bottom-right; defaults depend on the type",
+                    "type": "string",
+                    "enum": [
+                        "top-left",
+                        "top-right",
+                        "bottom-left",
+                        "bottom-right"
+                    ]
+                },
+                "style": {
+                    "description": "CSS properties applied on top of the default style of the type",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "logo",
+                        "lower_third",
+                        "live_badge"
+                    ]
+                }
+            }
+        },
+        "models.OverlayUpdate": {
+            "type": "object",
+            "properties": {
+                "hidden": {
+                    "type": "boolean"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PreflightCheck": {
+            "type": "object",
+            "properties": {
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PreflightReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PreflightCheck"
+                    }
+                },
+                "ok": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.Recording": {
+            "type": "object",
+            "properties": {
+                "duration_seconds": {
+                    "type": "number"
+                },
+                "ended_at": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size_bytes": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SRTOptions": {
+            "type": "object",
+            "properties": {
+                "latency_ms": {
+                    "type": "integer",
+                    "maximum": 8000,
+                    "minimum": 20
+                },
+                "passphrase": {
+                    "type": "string",
+                    "maxLength": 79,
+                    "minLength": 10
+                },
+                "pbkeylen": {
+                    "type": "integer",
+                    "enum": [
+                        16,
+                        24,
+                        32
+                    ]
+                },
+                "stream_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SessionEvent": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SessionState": {
+            "type": "string",
+            "enum": [
+                "starting",
+                "waiting_for_approval",
+                "live",
+                "ended",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "SessionStateStarting",
+                "SessionStateWaitingForApproval",
+                "SessionStateLive",
+                "SessionStateEnded",
+                "SessionStateFailed"
+            ]
+        },
+        "models.SessionType": {
+            "type": "string",
+            "enum": [
+                "broadcast",
+                "test"
+            ],
+            "x-enum-varnames": [
+                "SessionTypeBroadcast",
+                "SessionTypeTest"
+            ]
+        },
+        "models.Slate": {
+            "type": "object",
+            "properties": {
+                "background": {
+                    "description": "CSS background of the card, black by default",
+                    "type": "string"
+                },
+                "countdown_to": {
+                    "description": "Counts down to a scheduled start time, e.g. 2024-05-01T14:00:00Z",
+                    "type": "string"
+                },
+                "html": {
+                    "description": "Markup drawn on the card, below the image and text",
+                    "type": "string"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "page_url": {
+                    "description": "A page shown full-screen instead of the card's content",
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "video_url": {
+                    "description": "A video looped full-screen with its sound, behind the card's content",
+                    "type": "string"
+                }
+            }
+        },
+        "models.TestBroadcastRequest": {
+            "type": "object",
+            "properties": {
+                "audio": {
+                    "$ref": "#/definitions/models.AudioOptions"
+                },
+                "destinations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Destination"
+                    }
+                },
+                "duration_seconds": {
+                    "description": "How long the test stream runs before it stops by itself, 60 seconds by default",
+                    "type": "integer",
+                    "maximum": 600,
+                    "minimum": 10
+                },
+                "orientation": {
+                    "type": "string",
+                    "enum": [
+                        "landscape",
+                        "portrait"
+                    ]
+                },
+                "platform": {
+                    "type": "string",
+                    "enum": [
+                        "youtube",
+                        "twitch",
+                        "facebook",
+                        "kick",
+                        "linkedin",
+                        "tiktok",
+                        "custom"
+                    ]
+                },
+                "quality": {
+                    "type": "string",
+                    "enum": [
+                        "720p30",
+                        "1080p30",
+                        "1080p60",
+                        "audio_only"
+                    ]
+                },
+                "rtmp_url": {
+                    "type": "string"
+                },
+                "srt": {
+                    "$ref": "#/definitions/models.SRTOptions"
+                },
+                "stream_key": {
+                    "type": "string"
+                },
+                "whip_destinations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WHIPDestination"
+                    }
+                }
+            }
+        },
+        "models.WHIPDestination": {
+            "type": "object",
+            "required": [
+                "endpoint_url",
+                "label"
+            ],
+            "properties": {
+                "bearer_token": {
+                    "type": "string"
+                },
+                "endpoint_url": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "orientation": {
+                    "type": "string",
+                    "enum": [
+                        "landscape",
+                        "portrait"
+                    ]
                 }
             }
         }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"spoutbreeze/initializers"
 	"spoutbreeze/routes"
+	"spoutbreeze/services"
 
 	"github.com/gin-gonic/gin"
 
@@ -17,6 +18,11 @@ import (
 
 func init() {
 	initializers.LoadEnvVariables()
+
+	// Redis is optional, it is only needed for tenant profiles
+	if os.Getenv("REDIS_HOST") != "" {
+		initializers.ConnectToRedis()
+	}
 }
 
 //  @title SpoutBreeze API
@@ -39,6 +45,9 @@ func main() {
 
 	router := routes.SetupRouter()
 
+	services.StartRecordingRetention()
+	services.StartRTMPRelay()
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	PORT := os.Getenv("PORT")
@@ -1,14 +1,96 @@
 package models
 
 type BroadcasterRequest struct {
-	BBBServerURL string `json:"bbb_server_url" binding:"required"`
+	BBBServerURL string `json:"bbb_server_url" binding:"required_without=GreenlightRoomURL"`
 	BBBHealthCheckURL string `json:"bbb_health_check_url" binding:"required"`
-	RTMPURL      string `json:"rtmp_url" binding:"required"`
-	StreamKey    string `json:"stream_key" binding:"required"`
+	RTMPURL      string `json:"rtmp_url" binding:"required_without_all=Destinations Platform WHIPDestinations,startswith=rtmp://|startswith=rtmps://|startswith=srt://|startswith=icecast://|startswith=http://|startswith=https://|eq="`
+	StreamKey    string `json:"stream_key" binding:"required_without_all=Destinations WHIPDestinations"`
+	// Platform preset for stream_key, the ingest URL is built from it when rtmp_url is empty
+	Platform string `json:"platform,omitempty" binding:"omitempty,oneof=youtube twitch facebook kick linkedin tiktok custom"`
+	// Options for an srt:// rtmp_url
+	SRT *SRTOptions `json:"srt,omitempty"`
+	// Simulcast destinations, used instead of rtmp_url and stream_key
+	Destinations []Destination `json:"destinations,omitempty" binding:"omitempty,dive"`
+	// WebRTC (WHIP) endpoints the captured tab is published to, in addition to or instead of RTMP
+	WHIPDestinations []WHIPDestination `json:"whip_destinations,omitempty" binding:"omitempty,dive"`
+	// How long to wait in the BBB guest lobby for a moderator to approve the bot
+	GuestApprovalTimeoutSeconds int `json:"guest_approval_timeout_seconds,omitempty" binding:"omitempty,min=0"`
+	// Greenlight room link (e.g. https://gl.example.com/rooms/xyz-abc/join), used instead of a BBB API join link
+	GreenlightRoomURL string `json:"greenlight_room_url,omitempty"`
+	AccessCode        string `json:"access_code,omitempty"`
+	DisplayName       string `json:"display_name,omitempty"`
+	// Layout of the captured page, "clean" hides the BBB interface around the meeting content
+	Layout string `json:"layout,omitempty" binding:"omitempty,oneof=default clean"`
+	// Branding overlays, taken from the tenant profile when none are given
+	Overlays []Overlay `json:"overlays,omitempty" binding:"omitempty,dive"`
+	TenantID string    `json:"tenant_id,omitempty"`
+	// Rule-based layout changes driven by what happens in the meeting
+	AutoDirector *AutoDirector `json:"auto_director,omitempty"`
+	// Output quality profile, 1080p30 by default
+	Quality string `json:"quality,omitempty" binding:"omitempty,oneof=720p30 1080p30 1080p60 audio_only"`
+	// Orientation of rtmp_url, and of the bot once destinations are split by orientation
+	Orientation string `json:"orientation,omitempty" binding:"omitempty,oneof=landscape portrait"`
+	// Audio encoding, on top of the quality profile
+	Audio *AudioOptions `json:"audio,omitempty"`
+	// Also write the program output to a file, mp4 by default
+	Record          bool   `json:"record,omitempty"`
+	RecordingFormat string `json:"recording_format,omitempty" binding:"omitempty,oneof=mp4 mkv"`
+	// Moon debugging: live VNC access to the bot, and a video of its screen kept for postmortems
+	EnableVNC   bool `json:"enable_vnc,omitempty"`
+	EnableVideo bool `json:"enable_video,omitempty"`
+	// Seconds the output is held before it reaches the destinations, so a producer can dump it; needs the RTMP relay
+	DelaySeconds int `json:"delay_seconds,omitempty" binding:"omitempty,min=10,max=30"`
+	// Shown after a dump, "We'll be right back" by default
+	DumpSlate *Slate `json:"dump_slate,omitempty"`
+	// Shown until the meeting is running, and for ended_slate_seconds (default 30, 0 for no hold) after it ends
+	StartingSlate     *Slate `json:"starting_slate,omitempty"`
+	EndedSlate        *Slate `json:"ended_slate,omitempty"`
+	EndedSlateSeconds *int   `json:"ended_slate_seconds,omitempty" binding:"omitempty,min=0,max=3600"`
+	// Shown while the meeting is unreachable, e.g. a "technical difficulties" slate or a looping video_url
+	Fallback *Slate `json:"fallback,omitempty"`
 }
 
+// TestBroadcastRequest streams the built-in test page instead of a meeting,
+// to check destinations and stream keys ahead of an event.
+type TestBroadcastRequest struct {
+	RTMPURL          string            `json:"rtmp_url" binding:"required_without_all=Destinations Platform WHIPDestinations,startswith=rtmp://|startswith=rtmps://|startswith=srt://|startswith=icecast://|startswith=http://|startswith=https://|eq="`
+	StreamKey        string            `json:"stream_key" binding:"required_without_all=Destinations WHIPDestinations"`
+	Platform         string            `json:"platform,omitempty" binding:"omitempty,oneof=youtube twitch facebook kick linkedin tiktok custom"`
+	SRT              *SRTOptions       `json:"srt,omitempty"`
+	Destinations     []Destination     `json:"destinations,omitempty" binding:"omitempty,dive"`
+	WHIPDestinations []WHIPDestination `json:"whip_destinations,omitempty" binding:"omitempty,dive"`
+	// How long the test stream runs before it stops by itself, 60 seconds by default
+	DurationSeconds int           `json:"duration_seconds,omitempty" binding:"omitempty,min=10,max=600"`
+	Quality         string        `json:"quality,omitempty" binding:"omitempty,oneof=720p30 1080p30 1080p60 audio_only"`
+	Orientation     string        `json:"orientation,omitempty" binding:"omitempty,oneof=landscape portrait"`
+	Audio           *AudioOptions `json:"audio,omitempty"`
+}
+
+// Output quality profiles.
+const (
+	Quality720p30    = "720p30"
+	Quality1080p30   = "1080p30"
+	Quality1080p60   = "1080p60"
+	QualityAudioOnly = "audio_only"
+)
+
+// Audio codecs, MP3 and Opus only for audio_only.
+const (
+	AudioCodecAAC  = "aac"
+	AudioCodecMP3  = "mp3"
+	AudioCodecOpus = "opus"
+)
+
+const (
+	LayoutDefault = "default"
+	LayoutClean   = "clean"
+)
+
 type BroadcasterResponse struct {
-	Message string `json:"message"`
+	Message   string `json:"message"`
+	SessionID string `json:"session_id,omitempty"`
+	// Sessions of the extra bots started for destinations of another orientation
+	CompanionSessionIDs []string `json:"companion_session_ids,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Delay actions a producer can run against a delayed broadcast.
+const (
+	DelayActionDump   = "dump"
+	DelayActionResume = "resume"
+)
+
+type DelayActionRequest struct {
+	Action string `json:"action" binding:"required,oneof=dump resume"`
+}
+
+// DelayStatus is the delay buffer of a live broadcast. Dumped is set while
+// the slate is on air after a dump.
+type DelayStatus struct {
+	Seconds  int        `json:"seconds"`
+	Dumped   bool       `json:"dumped"`
+	DumpedAt *time.Time `json:"dumped_at,omitempty"`
+}
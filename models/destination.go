@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// Streaming platforms with known ingest URLs and stream-key formats.
+const (
+	PlatformYouTube  = "youtube"
+	PlatformTwitch   = "twitch"
+	PlatformFacebook = "facebook"
+	PlatformKick     = "kick"
+	PlatformLinkedIn = "linkedin"
+	PlatformTikTok   = "tiktok"
+	PlatformCustom   = "custom"
+)
+
+// Orientations of the bot's output. Portrait is 9:16 for Shorts, Reels and
+// TikTok Live.
+const (
+	OrientationLandscape = "landscape"
+	OrientationPortrait  = "portrait"
+)
+
+// Output protocols, taken from the destination URL scheme.
+const (
+	ProtocolRTMP  = "rtmp"
+	ProtocolRTMPS = "rtmps"
+	ProtocolSRT   = "srt"
+	ProtocolWHIP  = "whip"
+	// Icecast mounts, also reachable over plain HTTP PUT
+	ProtocolIcecast = "icecast"
+	ProtocolHTTP    = "http"
+	ProtocolHTTPS   = "https"
+)
+
+// AudioOptions set the audio encoding. Codecs other than AAC need the
+// audio_only quality profile.
+type AudioOptions struct {
+	Codec       string `json:"codec,omitempty" binding:"omitempty,oneof=aac mp3 opus"`
+	BitrateKbps int    `json:"bitrate_kbps,omitempty" binding:"omitempty,min=32,max=320"`
+	SampleRate  int    `json:"sample_rate,omitempty" binding:"omitempty,oneof=44100 48000"`
+	Channels    int    `json:"channels,omitempty" binding:"omitempty,oneof=1 2"`
+}
+
+// SRTOptions tune an srt:// destination. LatencyMs is the receiver latency
+// and PBKeyLen the AES key length used with the passphrase.
+type SRTOptions struct {
+	Passphrase string `json:"passphrase,omitempty" binding:"omitempty,min=10,max=79"`
+	LatencyMs  int    `json:"latency_ms,omitempty" binding:"omitempty,min=20,max=8000"`
+	StreamID   string `json:"stream_id,omitempty"`
+	PBKeyLen   int    `json:"pbkeylen,omitempty" binding:"omitempty,oneof=16 24 32"`
+}
+
+// Destination is one streaming endpoint a broadcast is pushed to. With a
+// platform preset the URL can be left out and is built from the preset.
+type Destination struct {
+	URL      string `json:"url" binding:"required_without=Platform,startswith=rtmp://|startswith=rtmps://|startswith=srt://|startswith=icecast://|startswith=http://|startswith=https://|eq="`
+	Key      string `json:"key"`
+	Label    string `json:"label" binding:"required"`
+	Platform string `json:"platform,omitempty" binding:"omitempty,oneof=youtube twitch facebook kick linkedin tiktok custom"`
+	// Push to the platform's backup ingest instead of the primary one
+	Backup bool `json:"backup,omitempty"`
+	// Options for srt:// URLs
+	SRT *SRTOptions `json:"srt,omitempty"`
+	// Portrait destinations are fed by a bot of their own
+	Orientation string `json:"orientation,omitempty" binding:"omitempty,oneof=landscape portrait"`
+}
+
+// WHIPDestination is a WebRTC-HTTP ingestion (WHIP) endpoint the bot
+// publishes the captured tab to from the browser, next to or instead of the
+// destinations pushed by the capture container.
+type WHIPDestination struct {
+	EndpointURL string `json:"endpoint_url" binding:"required,url"`
+	BearerToken string `json:"bearer_token,omitempty"`
+	Label       string `json:"label" binding:"required"`
+	Orientation string `json:"orientation,omitempty" binding:"omitempty,oneof=landscape portrait"`
+}
+
+const (
+	DestinationStatePending = "pending"
+	DestinationStateLive    = "live"
+	DestinationStateFailed  = "failed"
+	// The RTMP relay lost the destination and is connecting again
+	DestinationStateReconnecting = "reconnecting"
+)
+
+// DestinationStatus is reported by the capture container for each destination.
+type DestinationStatus struct {
+	Label       string    `json:"label"`
+	Platform    string    `json:"platform,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	Orientation string    `json:"orientation,omitempty"`
+	URL         string    `json:"url"`
+	State       string    `json:"state"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Traffic of destinations pushed through the RTMP relay
+	BytesSent   uint64  `json:"bytes_sent,omitempty"`
+	BitrateKbps float64 `json:"bitrate_kbps,omitempty"`
+	Reconnects  int     `json:"reconnects,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+// Layout actions a producer can run against a live broadcast.
+const (
+	LayoutActionPresentationFullscreen = "presentation_fullscreen"
+	LayoutActionFocusWebcams           = "focus_webcams"
+	LayoutActionSmartLayout            = "smart_layout"
+	LayoutActionSpeakerFocus           = "speaker_focus"
+	LayoutActionHideChat               = "hide_chat"
+	LayoutActionShowChat               = "show_chat"
+	LayoutActionHideUserList           = "hide_user_list"
+	LayoutActionShowUserList           = "show_user_list"
+)
+
+type LayoutActionRequest struct {
+	Action string `json:"action" binding:"required,oneof=presentation_fullscreen focus_webcams smart_layout speaker_focus hide_chat show_chat hide_user_list show_user_list"`
+}
+
+// Meeting events the auto-director reacts to.
+const (
+	DirectorEventScreenshareStarted   = "screenshare_started"
+	DirectorEventScreenshareStopped   = "screenshare_stopped"
+	DirectorEventPresenterChanged     = "presenter_changed"
+	DirectorEventPresentationUploaded = "presentation_uploaded"
+	DirectorEventWebcamsOn            = "webcams_on"
+	DirectorEventWebcamsOff           = "webcams_off"
+)
+
+// DirectorRule runs a layout action whenever a meeting event happens.
+type DirectorRule struct {
+	On     string `json:"on" binding:"required,oneof=screenshare_started screenshare_stopped presenter_changed presentation_uploaded webcams_on webcams_off"`
+	Action string `json:"action" binding:"required,oneof=presentation_fullscreen focus_webcams smart_layout speaker_focus hide_chat show_chat hide_user_list show_user_list"`
+}
+
+type AutoDirector struct {
+	Enabled bool `json:"enabled"`
+	// Defaults to fullscreen screenshare when one starts and smart layout when it stops
+	Rules []DirectorRule `json:"rules,omitempty" binding:"omitempty,dive"`
+}
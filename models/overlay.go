@@ -0,0 +1,33 @@
+package models
+
+const (
+	OverlayTypeLogo       = "logo"
+	OverlayTypeLowerThird = "lower_third"
+	OverlayTypeLiveBadge  = "live_badge"
+)
+
+// Overlay is a branding element drawn over the captured BBB page.
+type Overlay struct {
+	ID       string `json:"id" binding:"required"`
+	Type     string `json:"type" binding:"required,oneof=logo lower_third live_badge"`
+	ImageURL string `json:"image_url,omitempty" binding:"required_if=Type logo,omitempty,url"`
+	Text     string `json:"text,omitempty"`
+	// One of top-left, top-right, bottom-left, bottom-right; defaults depend on the type
+	Position string `json:"position,omitempty" binding:"omitempty,oneof=top-left top-right bottom-left bottom-right"`
+	// CSS properties applied on top of the default style of the type
+	Style  map[string]string `json:"style,omitempty"`
+	Hidden bool              `json:"hidden,omitempty"`
+}
+
+// OverlayUpdate changes an overlay of a live broadcast, nil fields are left as is.
+type OverlayUpdate struct {
+	Text     *string `json:"text"`
+	ImageURL *string `json:"image_url" binding:"omitempty,url"`
+	Hidden   *bool   `json:"hidden"`
+}
+
+// TenantProfile holds the branding a tenant's broadcasts get by default.
+type TenantProfile struct {
+	ID       string    `json:"id"`
+	Overlays []Overlay `json:"overlays"`
+}
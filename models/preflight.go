@@ -0,0 +1,23 @@
+package models
+
+const (
+	PreflightPassed  = "passed"
+	PreflightWarning = "warning"
+	PreflightFailed  = "failed"
+	PreflightSkipped = "skipped"
+)
+
+// PreflightCheck is the outcome of one check of a broadcast request.
+type PreflightCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// PreflightReport lists every check run for a broadcast request. OK is false
+// when any check failed, warnings do not count.
+type PreflightReport struct {
+	OK     bool             `json:"ok"`
+	Checks []PreflightCheck `json:"checks"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Recording file formats.
+const (
+	RecordingFormatMP4 = "mp4"
+	RecordingFormatMKV = "mkv"
+)
+
+const (
+	RecordingStateRecording = "recording"
+	RecordingStateCompleted = "completed"
+	// The file was removed by the retention policy
+	RecordingStateDeleted = "deleted"
+	// The Moon video could not be fetched
+	RecordingStateFailed = "failed"
+)
+
+// Where a recording comes from: the capture container's program output, or
+// Moon's video of the bot's screen.
+const (
+	RecordingSourceCapture   = "capture"
+	RecordingSourceMoonVideo = "moon_video"
+)
+
+// Recording is a file the capture container wrote next to the live output,
+// or a Moon session video. A session has one of each per bot browser start.
+type Recording struct {
+	Name            string     `json:"name"`
+	Source          string     `json:"source"`
+	Format          string     `json:"format"`
+	State           string     `json:"state"`
+	SizeBytes       int64      `json:"size_bytes"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// SessionType tells meeting broadcasts apart from test broadcasts.
+type SessionType string
+
+const (
+	SessionTypeBroadcast SessionType = "broadcast"
+	SessionTypeTest      SessionType = "test"
+)
+
+type SessionState string
+
+const (
+	SessionStateStarting           SessionState = "starting"
+	SessionStateWaitingForApproval SessionState = "waiting_for_approval"
+	SessionStateLive               SessionState = "live"
+	SessionStateEnded              SessionState = "ended"
+	SessionStateFailed             SessionState = "failed"
+)
+
+// Reason codes reported when a session fails.
+const (
+	ReasonGuestDenied          = "guest_denied"
+	ReasonGuestApprovalTimeout = "guest_approval_timeout"
+	ReasonInvalidAccessCode    = "invalid_access_code"
+	ReasonGreenlightJoinFailed = "greenlight_join_failed"
+	ReasonMeetingEnded         = "meeting_ended"
+	ReasonRemovedFromMeeting   = "removed_from_meeting"
+	ReasonConnectionLost       = "connection_lost"
+	ReasonTestCompleted        = "test_completed"
+	ReasonMeetingNotStarted    = "meeting_not_started"
+)
+
+type SessionEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+// BrowserLogEntry is a console or network message captured from the bot's Chrome.
+type BrowserLogEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Fatal   bool      `json:"fatal"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// DebugEndpoints reach the Moon session of a live bot started with enable_vnc
+// or enable_video. The VNC URL is only set with enable_vnc.
+type DebugEndpoints struct {
+	MoonSessionID string `json:"moon_session_id"`
+	VNCURL        string `json:"vnc_url,omitempty"`
+	DevtoolsURL   string `json:"devtools_url"`
+}
+
+type BroadcasterSession struct {
+	ID   string      `json:"id"`
+	Type SessionType `json:"type"`
+	// Bots started together to feed destinations of different orientations
+	ParentSessionID     string              `json:"parent_session_id,omitempty"`
+	CompanionSessionIDs []string            `json:"companion_session_ids,omitempty"`
+	State               SessionState        `json:"state"`
+	Reason              string              `json:"reason,omitempty"`
+	Diagnosis           string              `json:"diagnosis,omitempty"`
+	StartedAt           time.Time           `json:"started_at"`
+	Events              []SessionEvent      `json:"events"`
+	BrowserLogs         []BrowserLogEntry   `json:"browser_logs"`
+	Overlays            []Overlay           `json:"overlays,omitempty"`
+	Destinations        []DestinationStatus `json:"destinations"`
+	Debug               *DebugEndpoints     `json:"debug,omitempty"`
+	Delay               *DelayStatus        `json:"delay,omitempty"`
+	// Set while the fallback is on air because the meeting is unreachable
+	FallbackSince *time.Time `json:"fallback_since,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Slate is a full-screen card shown instead of the meeting, with the
+// meeting's audio muted.
+type Slate struct {
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty" binding:"omitempty,url"`
+	// Markup drawn on the card, below the image and text
+	HTML string `json:"html,omitempty"`
+	// A page shown full-screen instead of the card's content
+	PageURL string `json:"page_url,omitempty" binding:"omitempty,url"`
+	// A video looped full-screen with its sound, behind the card's content
+	VideoURL string `json:"video_url,omitempty" binding:"omitempty,url"`
+	// Counts down to a scheduled start time, e.g. 2024-05-01T14:00:00Z
+	CountdownTo *time.Time `json:"countdown_to,omitempty"`
+	// CSS background of the card, black by default
+	Background string `json:"background,omitempty"`
+}
@@ -0,0 +1,194 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AMF0 type markers used by RTMP commands and metadata.
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfLongString  = 0x0c
+)
+
+var errAMFShort = errors.New("amf: value is cut short")
+
+// amfObj is an AMF0 object. ECMA arrays decode to it as well.
+type amfObj map[string]interface{}
+
+// amfEncode writes values as AMF0. Numbers may be given as any Go integer
+// type, nil is written as null.
+func amfEncode(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, value := range values {
+		amfEncodeValue(&buf, value)
+	}
+	return buf.Bytes()
+}
+
+func amfEncodeValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(amfNull)
+	case bool:
+		buf.WriteByte(amfBoolean)
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case string:
+		if len(v) > math.MaxUint16 {
+			buf.WriteByte(amfLongString)
+			binary.Write(buf, binary.BigEndian, uint32(len(v)))
+		} else {
+			buf.WriteByte(amfString)
+			binary.Write(buf, binary.BigEndian, uint16(len(v)))
+		}
+		buf.WriteString(v)
+	case amfObj:
+		buf.WriteByte(amfObject)
+		// Sorted keys keep the encoding stable
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			binary.Write(buf, binary.BigEndian, uint16(len(key)))
+			buf.WriteString(key)
+			amfEncodeValue(buf, v[key])
+		}
+		buf.Write([]byte{0, 0, amfObjectEnd})
+	case float64:
+		buf.WriteByte(amfNumber)
+		binary.Write(buf, binary.BigEndian, v)
+	case int:
+		amfEncodeValue(buf, float64(v))
+	case uint32:
+		amfEncodeValue(buf, float64(v))
+	default:
+		panic(fmt.Sprintf("amf: cannot encode %T", value))
+	}
+}
+
+// amfDecode reads all AMF0 values of a command or data message.
+func amfDecode(data []byte) ([]interface{}, error) {
+	reader := bytes.NewReader(data)
+	var values []interface{}
+	for reader.Len() > 0 {
+		value, err := amfDecodeValue(reader)
+		if err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func amfDecodeValue(reader *bytes.Reader) (interface{}, error) {
+	marker, err := reader.ReadByte()
+	if err != nil {
+		return nil, errAMFShort
+	}
+	switch marker {
+	case amfNumber:
+		var number float64
+		if err := binary.Read(reader, binary.BigEndian, &number); err != nil {
+			return nil, errAMFShort
+		}
+		return number, nil
+	case amfBoolean:
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, errAMFShort
+		}
+		return b != 0, nil
+	case amfString:
+		return amfDecodeString(reader, 2)
+	case amfLongString:
+		return amfDecodeString(reader, 4)
+	case amfNull, amfUndefined:
+		return nil, nil
+	case amfObject:
+		return amfDecodeObject(reader)
+	case amfECMAArray:
+		// The count is only a hint, the entries end like an object
+		if _, err := reader.Seek(4, 1); err != nil {
+			return nil, errAMFShort
+		}
+		return amfDecodeObject(reader)
+	case amfStrictArray:
+		var count uint32
+		if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+			return nil, errAMFShort
+		}
+		var array []interface{}
+		for i := uint32(0); i < count; i++ {
+			value, err := amfDecodeValue(reader)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	default:
+		return nil, fmt.Errorf("amf: unsupported type 0x%02x", marker)
+	}
+}
+
+func amfDecodeString(reader *bytes.Reader, lengthSize int) (string, error) {
+	var length uint32
+	if lengthSize == 2 {
+		var short uint16
+		if err := binary.Read(reader, binary.BigEndian, &short); err != nil {
+			return "", errAMFShort
+		}
+		length = uint32(short)
+	} else if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", errAMFShort
+	}
+	if uint32(reader.Len()) < length {
+		return "", errAMFShort
+	}
+	buf := make([]byte, length)
+	reader.Read(buf)
+	return string(buf), nil
+}
+
+func amfDecodeObject(reader *bytes.Reader) (amfObj, error) {
+	object := amfObj{}
+	for {
+		key, err := amfDecodeString(reader, 2)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			end, err := reader.ReadByte()
+			if err != nil {
+				return nil, errAMFShort
+			}
+			if end != amfObjectEnd {
+				return nil, fmt.Errorf("amf: object has an empty key")
+			}
+			return object, nil
+		}
+		value, err := amfDecodeValue(reader)
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+	}
+}
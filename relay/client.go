@@ -0,0 +1,238 @@
+package relay
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Publisher pushes one stream to an RTMP or RTMPS server.
+type Publisher struct {
+	conn     *conn
+	streamID uint32
+
+	mu  sync.Mutex
+	err error
+}
+
+// SplitURL returns the address, application and stream name of an ingest URL
+// and stream key. Without a key the last path segment is the stream name.
+func SplitURL(rawURL string, streamKey string) (address string, app string, stream string, useTLS bool, err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", "", false, err
+	}
+	switch parsed.Scheme {
+	case "rtmp":
+	case "rtmps":
+		useTLS = true
+	default:
+		return "", "", "", false, fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "1935"
+		if useTLS {
+			port = "443"
+		}
+	}
+	address = net.JoinHostPort(parsed.Hostname(), port)
+
+	app = strings.Trim(parsed.Path, "/")
+	stream = streamKey
+	if stream == "" {
+		index := strings.LastIndex(app, "/")
+		if index == -1 {
+			return "", "", "", false, errors.New("ingest URL has no stream name")
+		}
+		app, stream = app[:index], app[index+1:]
+	}
+	if parsed.RawQuery != "" {
+		app += "?" + parsed.RawQuery
+	}
+	return address, app, stream, useTLS, nil
+}
+
+// Dial connects to an ingest and starts publishing the stream. The timeout
+// covers the whole setup.
+func Dial(rawURL string, streamKey string, timeout time.Duration) (*Publisher, error) {
+	address, app, stream, useTLS, err := SplitURL(rawURL, streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var netConn net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(address)
+		netConn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host})
+	} else {
+		netConn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	netConn.SetDeadline(time.Now().Add(timeout))
+
+	c := newConn(netConn)
+	publisher := &Publisher{conn: c}
+	err = publisher.setUp(app, strings.TrimSuffix(rawURL, "/"+stream), stream)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+
+	go publisher.drain()
+	return publisher, nil
+}
+
+func (p *Publisher) setUp(app string, tcURL string, stream string) error {
+	c := p.conn
+	if err := c.clientHandshake(); err != nil {
+		return err
+	}
+	if err := c.setChunkSize(outgoingChunkSize); err != nil {
+		return err
+	}
+
+	err := c.writeCommand(0, "connect", 1, amfObj{
+		"app":      app,
+		"type":     "nonprivate",
+		"flashVer": "FMLE/3.0 (compatible; FMSc/1.0)",
+		"tcUrl":    tcURL,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := p.awaitResult(1); err != nil {
+		return fmt.Errorf("connect rejected: %w", err)
+	}
+
+	c.writeCommand(0, "releaseStream", 2, nil, stream)
+	c.writeCommand(0, "FCPublish", 3, nil, stream)
+	if err := c.writeCommand(0, "createStream", 4, nil); err != nil {
+		return err
+	}
+	values, err := p.awaitResult(4)
+	if err != nil {
+		return fmt.Errorf("createStream rejected: %w", err)
+	}
+	streamID, _ := commandArg(values, 3).(float64)
+	p.streamID = uint32(streamID)
+
+	if err := c.writeCommand(p.streamID, "publish", 5, nil, stream, "live"); err != nil {
+		return err
+	}
+	for {
+		values, err := p.readCommand()
+		if err != nil {
+			return err
+		}
+		if name, _ := values[0].(string); name != "onStatus" {
+			continue
+		}
+		status, _ := commandArg(values, 3).(amfObj)
+		code, _ := status["code"].(string)
+		if code == "NetStream.Publish.Start" {
+			return nil
+		}
+		if level, _ := status["level"].(string); level == "error" {
+			description, _ := status["description"].(string)
+			return fmt.Errorf("publish rejected: %s %s", code, description)
+		}
+	}
+}
+
+// awaitResult waits for the answer to a command.
+func (p *Publisher) awaitResult(transactionID float64) ([]interface{}, error) {
+	for {
+		values, err := p.readCommand()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := values[0].(string)
+		id, _ := values[1].(float64)
+		if id != transactionID {
+			continue
+		}
+		switch name {
+		case "_result":
+			return values, nil
+		case "_error":
+			if status, ok := commandArg(values, 3).(amfObj); ok {
+				return nil, fmt.Errorf("%v %v", status["code"], status["description"])
+			}
+			return nil, errors.New("server answered _error")
+		}
+	}
+}
+
+func (p *Publisher) readCommand() ([]interface{}, error) {
+	for {
+		message, err := p.conn.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		if message.Type != msgCommandAMF0 {
+			continue
+		}
+		values, err := amfDecode(message.Payload)
+		if err != nil || len(values) < 2 {
+			continue
+		}
+		return values, nil
+	}
+}
+
+// drain keeps reading what the server sends while publishing, so its pings
+// are answered and a disconnect or error status is noticed.
+func (p *Publisher) drain() {
+	for {
+		values, err := p.readCommand()
+		if err == nil {
+			status, _ := commandArg(values, 3).(amfObj)
+			if level, _ := status["level"].(string); level != "error" {
+				continue
+			}
+			err = fmt.Errorf("server reported %v", status["code"])
+		}
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+		p.conn.Close()
+		return
+	}
+}
+
+// WriteMessage sends a media or data message on the published stream.
+func (p *Publisher) WriteMessage(message *Message, timeout time.Duration) error {
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	out := *message
+	out.StreamID = p.streamID
+	p.conn.netConn.SetWriteDeadline(time.Now().Add(timeout))
+	return p.conn.writeMessage(&out)
+}
+
+// BytesSent is the media and data payload sent so far.
+func (p *Publisher) BytesSent() uint64 {
+	return p.conn.BytesWritten()
+}
+
+// Close ends the stream and the connection.
+func (p *Publisher) Close() error {
+	p.conn.netConn.SetWriteDeadline(time.Now().Add(time.Second))
+	p.conn.writeCommand(p.streamID, "FCUnpublish", 0, nil, "")
+	p.conn.writeCommand(p.streamID, "deleteStream", 0, nil, p.streamID)
+	return p.conn.Close()
+}
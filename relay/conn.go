@@ -0,0 +1,424 @@
+package relay
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Types of the messages a publisher streams: FLV audio and video tags, and
+// AMF0 data such as @setDataFrame metadata.
+const (
+	MessageAudio = 8
+	MessageVideo = 9
+	MessageData  = 18
+)
+
+// RTMP protocol control and command message types.
+const (
+	msgSetChunkSize     = 1
+	msgAbort            = 2
+	msgAck              = 3
+	msgUserControl      = 4
+	msgWindowAckSize    = 5
+	msgSetPeerBandwidth = 6
+	msgDataAMF3         = 15
+	msgCommandAMF3      = 17
+	msgCommandAMF0      = 20
+)
+
+// Chunk stream IDs messages are written on.
+const (
+	csidControl = 2
+	csidCommand = 3
+	csidAudio   = 4
+	csidData    = 5
+	csidVideo   = 6
+)
+
+const (
+	handshakeSize     = 1536
+	defaultChunkSize  = 128
+	outgoingChunkSize = 4096
+	maxChunkSize      = 1 << 24
+	windowAckSize     = 2500000
+	maxMessageLength  = 16 << 20
+
+	userControlPingRequest  = 6
+	userControlPingResponse = 7
+)
+
+// Message is a complete RTMP message.
+type Message struct {
+	Type      uint8
+	StreamID  uint32
+	Timestamp uint32
+	Payload   []byte
+}
+
+// chunkStream is the header state of one incoming chunk stream.
+type chunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typ       uint8
+	streamID  uint32
+	extended  bool
+	buf       []byte
+}
+
+// conn reads and writes RTMP messages over a connection after the handshake.
+// Protocol control messages are handled here, the caller only sees commands,
+// data and media. Reads happen on one goroutine, writes may come from several.
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+
+	readChunkSize  uint32
+	streams        map[uint32]*chunkStream
+	bytesRead      uint64
+	lastAck        uint64
+	peerWindowSize uint32
+
+	writeMu        sync.Mutex
+	writer         *bufio.Writer
+	writeChunkSize uint32
+	// bytesWritten is read for stats while a write may be blocked on the network
+	bytesWritten atomic.Uint64
+}
+
+func newConn(netConn net.Conn) *conn {
+	c := &conn{
+		netConn:        netConn,
+		readChunkSize:  defaultChunkSize,
+		writeChunkSize: defaultChunkSize,
+		streams:        map[uint32]*chunkStream{},
+		writer:         bufio.NewWriterSize(netConn, 64*1024),
+	}
+	c.reader = bufio.NewReaderSize(countingReader{conn: c}, 64*1024)
+	return c
+}
+
+type countingReader struct {
+	conn *conn
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.conn.netConn.Read(p)
+	r.conn.bytesRead += uint64(n)
+	return n, err
+}
+
+func (c *conn) Close() error {
+	return c.netConn.Close()
+}
+
+// serverHandshake answers a client's plain RTMP handshake.
+func (c *conn) serverHandshake() error {
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(c.reader, c0c1); err != nil {
+		return fmt.Errorf("failed to read handshake: %w", err)
+	}
+	if c0c1[0] != 3 {
+		return fmt.Errorf("unsupported RTMP version %d", c0c1[0])
+	}
+	s1 := make([]byte, handshakeSize)
+	rand.Read(s1[8:])
+	response := append([]byte{3}, s1...)
+	response = append(response, c0c1[1:]...)
+	if err := c.writeRaw(response); err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+	c2 := make([]byte, handshakeSize)
+	if _, err := io.ReadFull(c.reader, c2); err != nil {
+		return fmt.Errorf("failed to read handshake: %w", err)
+	}
+	return nil
+}
+
+// clientHandshake runs the plain RTMP handshake with a server.
+func (c *conn) clientHandshake() error {
+	c1 := make([]byte, handshakeSize)
+	binary.BigEndian.PutUint32(c1, uint32(time.Now().Unix()))
+	rand.Read(c1[8:])
+	if err := c.writeRaw(append([]byte{3}, c1...)); err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+	s0s1s2 := make([]byte, 1+2*handshakeSize)
+	if _, err := io.ReadFull(c.reader, s0s1s2); err != nil {
+		return fmt.Errorf("failed to read handshake: %w", err)
+	}
+	if s0s1s2[0] != 3 {
+		return fmt.Errorf("server answered with RTMP version %d", s0s1s2[0])
+	}
+	if err := c.writeRaw(s0s1s2[1 : 1+handshakeSize]); err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+	return nil
+}
+
+func (c *conn) writeRaw(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.writer.Write(data); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+// readMessage returns the next command, data or media message.
+func (c *conn) readMessage() (*Message, error) {
+	for {
+		message, err := c.readChunk()
+		if err != nil {
+			return nil, err
+		}
+		if message == nil {
+			continue
+		}
+		if err := c.acknowledge(); err != nil {
+			return nil, err
+		}
+		handled, err := c.handleControl(message)
+		if err != nil {
+			return nil, err
+		}
+		if !handled {
+			return message, nil
+		}
+	}
+}
+
+// readChunk reads one chunk and returns the message it completes, if any.
+func (c *conn) readChunk() (*Message, error) {
+	first, err := c.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	format := first >> 6
+	csid := uint32(first & 0x3f)
+	switch csid {
+	case 0:
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b)
+	case 1:
+		var b [2]byte
+		if _, err := io.ReadFull(c.reader, b[:]); err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b[0]) + uint32(b[1])*256
+	}
+
+	stream := c.streams[csid]
+	if stream == nil {
+		if format != 0 {
+			return nil, fmt.Errorf("chunk stream %d starts without a full header", csid)
+		}
+		stream = &chunkStream{}
+		c.streams[csid] = stream
+	}
+
+	var header [11]byte
+	headerSizes := [4]int{11, 7, 3, 0}
+	if _, err := io.ReadFull(c.reader, header[:headerSizes[format]]); err != nil {
+		return nil, err
+	}
+	newMessage := len(stream.buf) == 0
+	timestampField := uint32(0)
+	if format < 3 {
+		timestampField = uint32(header[0])<<16 | uint32(header[1])<<8 | uint32(header[2])
+		stream.extended = timestampField == 0xffffff
+	}
+	if format < 2 {
+		stream.length = uint32(header[3])<<16 | uint32(header[4])<<8 | uint32(header[5])
+		stream.typ = header[6]
+	}
+	if format == 0 {
+		stream.streamID = binary.LittleEndian.Uint32(header[7:11])
+	}
+	if stream.extended {
+		var extended [4]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return nil, err
+		}
+		if format < 3 {
+			timestampField = binary.BigEndian.Uint32(extended[:])
+		}
+	}
+	switch {
+	case format == 0:
+		stream.timestamp = timestampField
+		stream.delta = timestampField
+	case format < 3:
+		stream.delta = timestampField
+		stream.timestamp += timestampField
+	case newMessage:
+		stream.timestamp += stream.delta
+	}
+
+	if stream.length > maxMessageLength {
+		return nil, fmt.Errorf("message of %d bytes is too large", stream.length)
+	}
+	if newMessage {
+		stream.buf = make([]byte, 0, stream.length)
+	}
+	size := stream.length - uint32(len(stream.buf))
+	if size > c.readChunkSize {
+		size = c.readChunkSize
+	}
+	start := len(stream.buf)
+	stream.buf = stream.buf[:start+int(size)]
+	if _, err := io.ReadFull(c.reader, stream.buf[start:]); err != nil {
+		return nil, err
+	}
+	if uint32(len(stream.buf)) < stream.length {
+		return nil, nil
+	}
+
+	message := &Message{Type: stream.typ, StreamID: stream.streamID, Timestamp: stream.timestamp, Payload: stream.buf}
+	stream.buf = nil
+	return message, nil
+}
+
+// acknowledge sends an Acknowledgement each time the peer's window is read.
+func (c *conn) acknowledge() error {
+	if c.peerWindowSize == 0 || c.bytesRead-c.lastAck < uint64(c.peerWindowSize) {
+		return nil
+	}
+	c.lastAck = c.bytesRead
+	return c.writeControl(msgAck, uint32(c.bytesRead))
+}
+
+func (c *conn) handleControl(message *Message) (bool, error) {
+	payload := message.Payload
+	switch message.Type {
+	case msgSetChunkSize:
+		if len(payload) < 4 {
+			return true, errors.New("short Set Chunk Size message")
+		}
+		size := binary.BigEndian.Uint32(payload) & 0x7fffffff
+		if size == 0 || size > maxChunkSize {
+			return true, fmt.Errorf("invalid chunk size %d", size)
+		}
+		c.readChunkSize = size
+	case msgAbort:
+		if len(payload) >= 4 {
+			if stream := c.streams[binary.BigEndian.Uint32(payload)]; stream != nil {
+				stream.buf = nil
+			}
+		}
+	case msgWindowAckSize:
+		if len(payload) >= 4 {
+			c.peerWindowSize = binary.BigEndian.Uint32(payload)
+		}
+	case msgAck, msgSetPeerBandwidth:
+	case msgUserControl:
+		if len(payload) >= 6 && binary.BigEndian.Uint16(payload) == userControlPingRequest {
+			response := append([]byte{0, userControlPingResponse}, payload[2:6]...)
+			return true, c.writeMessage(&Message{Type: msgUserControl, Payload: response})
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *conn) writeControl(messageType uint8, value uint32) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, value)
+	return c.writeMessage(&Message{Type: messageType, Payload: payload})
+}
+
+// setChunkSize announces and uses a larger chunk size for writing.
+func (c *conn) setChunkSize(size uint32) error {
+	err := c.writeControl(msgSetChunkSize, size)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	c.writeChunkSize = size
+	c.writeMu.Unlock()
+	return nil
+}
+
+func (c *conn) writeCommand(streamID uint32, values ...interface{}) error {
+	return c.writeMessage(&Message{Type: msgCommandAMF0, StreamID: streamID, Payload: amfEncode(values...)})
+}
+
+// writeMessage writes a message as a full header chunk followed by type 3
+// continuation chunks.
+func (c *conn) writeMessage(message *Message) error {
+	err := c.writeChunks(message)
+	if err == nil {
+		c.bytesWritten.Add(uint64(len(message.Payload)))
+	}
+	return err
+}
+
+func (c *conn) writeChunks(message *Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	csid := byte(csidCommand)
+	switch message.Type {
+	case msgSetChunkSize, msgAbort, msgAck, msgUserControl, msgWindowAckSize, msgSetPeerBandwidth:
+		csid = csidControl
+	case MessageAudio:
+		csid = csidAudio
+	case MessageVideo:
+		csid = csidVideo
+	case MessageData, msgDataAMF3:
+		csid = csidData
+	}
+
+	extended := message.Timestamp >= 0xffffff
+	timestampField := message.Timestamp
+	if extended {
+		timestampField = 0xffffff
+	}
+	length := len(message.Payload)
+	header := []byte{
+		csid,
+		byte(timestampField >> 16), byte(timestampField >> 8), byte(timestampField),
+		byte(length >> 16), byte(length >> 8), byte(length),
+		message.Type,
+		0, 0, 0, 0,
+	}
+	binary.LittleEndian.PutUint32(header[8:], message.StreamID)
+	var extendedTimestamp []byte
+	if extended {
+		extendedTimestamp = binary.BigEndian.AppendUint32(nil, message.Timestamp)
+	}
+
+	written := 0
+	for first := true; first || written < length; first = false {
+		if first {
+			c.writer.Write(header)
+		} else {
+			c.writer.WriteByte(0xc0 | csid)
+		}
+		c.writer.Write(extendedTimestamp)
+		size := length - written
+		if size > int(c.writeChunkSize) {
+			size = int(c.writeChunkSize)
+		}
+		c.writer.Write(message.Payload[written : written+size])
+		written += size
+	}
+	return c.writer.Flush()
+}
+
+// BytesWritten is the payload sent so far.
+func (c *conn) BytesWritten() uint64 {
+	return c.bytesWritten.Load()
+}
@@ -0,0 +1,187 @@
+package relay
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	delayTick = 20 * time.Millisecond
+	// dumpSettle is how long the publisher's messages are dropped after a
+	// dump. They were encoded before the slate went up.
+	dumpSettle = 3 * time.Second
+	// freezeInterval is how often the last slate keyframe is repeated while
+	// the buffer refills after a resume.
+	freezeInterval = 500 * time.Millisecond
+)
+
+// ErrNoDelay is returned when dumping a stream that is not delayed.
+var ErrNoDelay = errors.New("stream has no delay")
+
+type delayedMessage struct {
+	message *Message
+	at      time.Time
+}
+
+// delayLine holds the messages of a delayed route until they are due.
+//
+// A dump drops the buffer and, once the messages encoded before the slate
+// went up have passed, forwards the stream without delay; that is the slate.
+// Timestamps move back by the delay so the destinations see a gap of
+// dumpSettle rather than a jump. A resume brings the delay back: the buffer
+// refills for the length of the delay, during which the last slate keyframe
+// is repeated, and the timestamps move forward again.
+//
+// The fields are guarded by the route's mutex.
+type delayLine struct {
+	delay    time.Duration
+	done     chan struct{}
+	stopOnce sync.Once
+
+	buffer         []delayedMessage
+	dumped         bool
+	settleUntil    time.Time
+	awaitKeyframe  bool
+	offset         int64
+	lastTimestamps map[uint8]int64
+	lastVideoAt    time.Time
+	freezeFrame    *Message
+	refillUntil    time.Time
+}
+
+func newDelayLine(delay time.Duration) *delayLine {
+	return &delayLine{
+		delay:          delay,
+		done:           make(chan struct{}),
+		lastTimestamps: map[uint8]int64{},
+	}
+}
+
+func (d *delayLine) stop() {
+	d.stopOnce.Do(func() { close(d.done) })
+}
+
+func isHeader(message *Message) bool {
+	return message.Type == MessageData || isVideoSequenceHeader(message) || isAudioSequenceHeader(message)
+}
+
+// push takes a message of the publisher. Called with the route's lock held.
+func (rt *route) push(message *Message, now time.Time) {
+	d := rt.delayLine
+	if !isHeader(message) {
+		if now.Before(d.settleUntil) {
+			return
+		}
+		if d.awaitKeyframe {
+			if !isKeyframe(message) {
+				return
+			}
+			d.awaitKeyframe = false
+		}
+	}
+	if d.dumped {
+		rt.forward(message, now)
+		return
+	}
+	d.buffer = append(d.buffer, delayedMessage{message: message, at: now})
+}
+
+// runDelay releases the buffered messages as they become due.
+func (rt *route) runDelay() {
+	ticker := time.NewTicker(delayTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rt.delayLine.done:
+			return
+		case now := <-ticker.C:
+			rt.release(now)
+		}
+	}
+}
+
+func (rt *route) release(now time.Time) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	d := rt.delayLine
+
+	released := 0
+	for _, delayed := range d.buffer {
+		if now.Sub(delayed.at) < d.delay {
+			break
+		}
+		rt.forward(delayed.message, now)
+		released++
+	}
+	d.buffer = d.buffer[released:]
+
+	if now.Before(d.refillUntil) && d.freezeFrame != nil && now.Sub(d.lastVideoAt) >= freezeInterval {
+		frame := *d.freezeFrame
+		timestamp := d.lastTimestamps[MessageVideo] + now.Sub(d.lastVideoAt).Milliseconds()
+		frame.Timestamp = uint32(timestamp)
+		d.lastTimestamps[MessageVideo] = timestamp
+		d.lastVideoAt = now
+		rt.send(&frame)
+	}
+}
+
+// forward sends a message on with its timestamp moved by the offset of the
+// dumps and resumes so far, never going back in time. Called with the
+// route's lock held.
+func (rt *route) forward(message *Message, now time.Time) {
+	d := rt.delayLine
+	out := *message
+	timestamp := int64(message.Timestamp) + d.offset
+	if last, ok := d.lastTimestamps[message.Type]; ok && timestamp < last {
+		timestamp = last
+	}
+	if timestamp < 0 {
+		timestamp = 0
+	}
+	out.Timestamp = uint32(timestamp)
+	d.lastTimestamps[message.Type] = timestamp
+
+	if message.Type == MessageVideo {
+		d.lastVideoAt = now
+		if isKeyframe(message) && !isVideoSequenceHeader(message) {
+			d.freezeFrame = &out
+		}
+	}
+	rt.send(&out)
+}
+
+func (rt *route) dump(now time.Time) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	d := rt.delayLine
+	if d == nil {
+		return ErrNoDelay
+	}
+	d.buffer = nil
+	d.settleUntil = now.Add(dumpSettle)
+	d.awaitKeyframe = true
+	d.freezeFrame = nil
+	d.refillUntil = time.Time{}
+	if !d.dumped {
+		d.dumped = true
+		d.offset -= d.delay.Milliseconds()
+	}
+	return nil
+}
+
+func (rt *route) resume(now time.Time) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	d := rt.delayLine
+	if d == nil {
+		return ErrNoDelay
+	}
+	if !d.dumped {
+		return nil
+	}
+	d.dumped = false
+	d.offset += d.delay.Milliseconds()
+	d.refillUntil = now.Add(d.delay)
+	return nil
+}
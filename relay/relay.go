@@ -0,0 +1,518 @@
+// Package relay is a small RTMP ingest that forwards each published stream
+// to a set of RTMP and RTMPS destinations. Every destination has its own
+// connection, queue and reconnect loop, so one that drops does not affect the
+// others or the publisher.
+package relay
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Destination states reported through the state callback and Stats.
+const (
+	StateConnecting   = "connecting"
+	StateLive         = "live"
+	StateReconnecting = "reconnecting"
+	StateStopped      = "stopped"
+)
+
+const (
+	dialTimeout      = 10 * time.Second
+	writeTimeout     = 10 * time.Second
+	minBackoff       = time.Second
+	maxBackoff       = 30 * time.Second
+	outputQueueSize  = 2048
+	bitrateInterval  = 2 * time.Second
+	stableConnection = time.Minute
+)
+
+// ErrUnknownStream rejects publishers whose stream key is not registered.
+var ErrUnknownStream = errors.New("unknown stream key")
+
+// Destination is where a relayed stream is pushed.
+type Destination struct {
+	Label string
+	URL   string
+	Key   string
+}
+
+// DestinationStats describe how a destination is doing.
+type DestinationStats struct {
+	Label           string
+	State           string
+	BytesSent       uint64
+	BitrateKbps     float64
+	Reconnects      int
+	DroppedMessages uint64
+	LastError       string
+}
+
+// StateFunc is told about each destination state change.
+type StateFunc func(label string, state string, message string)
+
+// Relay is the RTMP ingest. Streams are registered by key before the
+// publisher connects.
+type Relay struct {
+	server Server
+
+	mu     sync.Mutex
+	routes map[string]*route
+}
+
+func New() *Relay {
+	r := &Relay{routes: map[string]*route{}}
+	r.server.OnPublish = r.publish
+	return r
+}
+
+// ListenAndServe accepts publishers on the TCP address.
+func (r *Relay) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return r.Serve(listener)
+}
+
+func (r *Relay) Serve(listener net.Listener) error {
+	return r.server.Serve(listener)
+}
+
+// Close disconnects publishers and stops every destination.
+func (r *Relay) Close() error {
+	err := r.server.Close()
+	r.mu.Lock()
+	routes := r.routes
+	r.routes = map[string]*route{}
+	r.mu.Unlock()
+	for _, route := range routes {
+		route.stop()
+	}
+	return err
+}
+
+// Register sets the destinations of a stream key and how long its messages
+// are held before they are sent on. A key that is already registered keeps
+// its destinations and their connections, so a publisher can come back
+// without the destinations reconnecting.
+func (r *Relay) Register(streamKey string, destinations []Destination, delay time.Duration, onState StateFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.routes[streamKey]; ok {
+		return
+	}
+	route := &route{streamKey: streamKey, sources: map[string]*source{}}
+	route.active = route.addSource(streamKey)
+	if delay > 0 {
+		route.delayLine = newDelayLine(delay)
+	}
+	for _, destination := range destinations {
+		route.outputs = append(route.outputs, newOutput(route, destination, onState))
+	}
+	r.routes[streamKey] = route
+}
+
+// Unregister stops the destinations of a stream key and forgets its
+// standby keys.
+func (r *Relay) Unregister(streamKey string) {
+	r.mu.Lock()
+	route := r.routes[streamKey]
+	if route != nil {
+		for _, key := range route.sourceKeys() {
+			delete(r.routes, key)
+		}
+	}
+	r.mu.Unlock()
+	if route != nil {
+		route.stop()
+	}
+}
+
+// Stats returns the destinations of a stream key, nil when it is unknown.
+func (r *Relay) Stats(streamKey string) []DestinationStats {
+	route := r.route(streamKey)
+	if route == nil {
+		return nil
+	}
+	stats := make([]DestinationStats, 0, len(route.outputs))
+	for _, output := range route.outputs {
+		stats = append(stats, output.stats())
+	}
+	return stats
+}
+
+// Dump drops the delayed messages of a stream key and sends the stream on
+// without delay until Resume.
+func (r *Relay) Dump(streamKey string) error {
+	route := r.route(streamKey)
+	if route == nil {
+		return ErrUnknownStream
+	}
+	return route.dump(time.Now())
+}
+
+// Resume brings the delay of a dumped stream key back.
+func (r *Relay) Resume(streamKey string) error {
+	route := r.route(streamKey)
+	if route == nil {
+		return ErrUnknownStream
+	}
+	return route.resume(time.Now())
+}
+
+// AddStandby lets a second publisher connect to a stream under its own key.
+// It reaches the destinations only after Switch.
+func (r *Relay) AddStandby(streamKey string, standbyKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	route := r.routes[streamKey]
+	if route == nil {
+		return ErrUnknownStream
+	}
+	if _, ok := r.routes[standbyKey]; !ok {
+		route.addSource(standbyKey)
+		r.routes[standbyKey] = route
+	}
+	return nil
+}
+
+// RemoveStandby forgets a standby key that was not switched to, so its
+// publisher can no longer connect. The active publisher's key is kept.
+func (r *Relay) RemoveStandby(streamKey string, standbyKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	route := r.routes[streamKey]
+	if route == nil || r.routes[standbyKey] != route {
+		return ErrUnknownStream
+	}
+	if route.removeSource(standbyKey) {
+		delete(r.routes, standbyKey)
+	}
+	return nil
+}
+
+// Switch hands a stream over to the publisher of a standby key at its next
+// keyframe. The destinations stay connected and see the new publisher's
+// stream carry on from the old one's. It returns ErrSourceNotLive when no
+// keyframe comes before the timeout, and the old publisher stays on.
+func (r *Relay) Switch(streamKey string, standbyKey string, timeout time.Duration) error {
+	route := r.route(streamKey)
+	if route == nil || r.route(standbyKey) != route {
+		return ErrUnknownStream
+	}
+	return route.switchTo(route.source(standbyKey), timeout)
+}
+
+func (r *Relay) route(streamKey string) *route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.routes[streamKey]
+}
+
+func (r *Relay) publish(app string, streamKey string) (Publication, error) {
+	route := r.route(streamKey)
+	if route == nil {
+		return nil, ErrUnknownStream
+	}
+	route.start()
+	src := route.source(streamKey)
+	route.rejoin(src)
+	return src, nil
+}
+
+// route fans a stream out to its destinations. Of its sources, only the
+// active one reaches them. A delayed route has a delay line in front of the
+// outputs.
+type route struct {
+	streamKey string
+	outputs   []*output
+	delayLine *delayLine
+
+	mu            sync.Mutex
+	started       bool
+	sources       map[string]*source
+	active        *source
+	pending       *source
+	switched      chan struct{}
+	lastTimestamp int64
+	// live is set once media reached the destinations
+	live bool
+}
+
+func (rt *route) start() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.started {
+		return
+	}
+	rt.started = true
+	for _, output := range rt.outputs {
+		go output.run()
+	}
+	if rt.delayLine != nil {
+		go rt.runDelay()
+	}
+}
+
+func (rt *route) stop() {
+	if rt.delayLine != nil {
+		rt.delayLine.stop()
+	}
+	for _, output := range rt.outputs {
+		output.stop()
+	}
+}
+
+// deliver passes messages of the active source on. Called with the lock
+// held; it is released.
+func (rt *route) deliver(messages ...*Message) {
+	if rt.delayLine != nil {
+		now := time.Now()
+		for _, message := range messages {
+			rt.push(message, now)
+		}
+		rt.mu.Unlock()
+		return
+	}
+	rt.mu.Unlock()
+
+	for _, message := range messages {
+		rt.send(message)
+	}
+}
+
+func (rt *route) send(message *Message) {
+	for _, output := range rt.outputs {
+		output.enqueue(message)
+	}
+}
+
+// headers are the sequence headers and metadata of the active source, which
+// a destination needs first whenever it (re)connects.
+func (rt *route) headers() []*Message {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.active.headers()
+}
+
+// FLV tag helpers: AVC and HEVC sequence headers, AAC sequence headers and
+// video keyframes.
+func isVideoSequenceHeader(message *Message) bool {
+	return message.Type == MessageVideo && len(message.Payload) > 1 && message.Payload[0]&0x0f >= 7 && message.Payload[1] == 0
+}
+
+func isAudioSequenceHeader(message *Message) bool {
+	return message.Type == MessageAudio && len(message.Payload) > 1 && message.Payload[0]>>4 == 10 && message.Payload[1] == 0
+}
+
+func isKeyframe(message *Message) bool {
+	return message.Type == MessageVideo && len(message.Payload) > 0 && message.Payload[0]>>4 == 1
+}
+
+// output keeps one destination connected and feeds it from its queue.
+type output struct {
+	route       *route
+	destination Destination
+	onState     StateFunc
+	queue       chan *Message
+	done        chan struct{}
+	stopOnce    sync.Once
+
+	mu          sync.Mutex
+	state       string
+	lastError   string
+	reconnects  int
+	dropped     uint64
+	sentBefore  uint64
+	publisher   *Publisher
+	sampleBytes uint64
+	sampleAt    time.Time
+	bitrateKbps float64
+}
+
+func newOutput(route *route, destination Destination, onState StateFunc) *output {
+	return &output{
+		route:       route,
+		destination: destination,
+		onState:     onState,
+		queue:       make(chan *Message, outputQueueSize),
+		done:        make(chan struct{}),
+		state:       StateConnecting,
+	}
+}
+
+// enqueue never blocks the publisher. A destination that cannot keep up, or
+// is reconnecting, loses messages instead.
+func (o *output) enqueue(message *Message) {
+	select {
+	case o.queue <- message:
+	default:
+		o.mu.Lock()
+		o.dropped++
+		o.mu.Unlock()
+	}
+}
+
+func (o *output) stop() {
+	o.stopOnce.Do(func() {
+		close(o.done)
+		o.setState(StateStopped, "")
+	})
+}
+
+func (o *output) stopped() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// run connects and reconnects the destination with exponential backoff
+// until the output is stopped.
+func (o *output) run() {
+	backoff := minBackoff
+	for !o.stopped() {
+		connectedAt := time.Now()
+		err := o.stream()
+		if o.stopped() {
+			return
+		}
+		if time.Since(connectedAt) >= stableConnection {
+			backoff = minBackoff
+		}
+		log.Printf("RTMP relay: destination %s: %v, reconnecting in %s", o.destination.Label, err, backoff)
+		o.mu.Lock()
+		o.reconnects++
+		o.mu.Unlock()
+		o.setState(StateReconnecting, err.Error())
+
+		select {
+		case <-o.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// stream publishes to the destination until the connection fails or the
+// output is stopped. Messages queued while disconnected are stale and
+// skipped; the destination starts with the stream's headers and its video
+// with the next keyframe.
+func (o *output) stream() error {
+	publisher, err := Dial(o.destination.URL, o.destination.Key, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	o.mu.Lock()
+	o.publisher = publisher
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		o.sentBefore += publisher.BytesSent()
+		o.publisher = nil
+		o.mu.Unlock()
+	}()
+
+	for len(o.queue) > 0 {
+		<-o.queue
+	}
+	for _, header := range o.route.headers() {
+		if err := publisher.WriteMessage(header, writeTimeout); err != nil {
+			return err
+		}
+	}
+	o.setState(StateLive, "")
+
+	ticker := time.NewTicker(bitrateInterval)
+	defer ticker.Stop()
+	waitingForKeyframe := true
+	for {
+		select {
+		case <-o.done:
+			return nil
+		case <-ticker.C:
+			o.sampleBitrate()
+		case message := <-o.queue:
+			if message.Type == MessageVideo && waitingForKeyframe {
+				if !isKeyframe(message) {
+					continue
+				}
+				waitingForKeyframe = false
+			}
+			if err := publisher.WriteMessage(message, writeTimeout); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (o *output) setState(state string, message string) {
+	o.mu.Lock()
+	if o.state == state && o.lastError == message {
+		o.mu.Unlock()
+		return
+	}
+	o.state = state
+	if message != "" {
+		o.lastError = message
+	}
+	o.mu.Unlock()
+
+	if o.onState != nil {
+		o.onState(o.destination.Label, state, message)
+	}
+}
+
+// bytesSent is the total over all connections. Called with the lock held.
+func (o *output) bytesSent() uint64 {
+	sent := o.sentBefore
+	if o.publisher != nil {
+		sent += o.publisher.BytesSent()
+	}
+	return sent
+}
+
+// sampleBitrate measures the bitrate since the last sample.
+func (o *output) sampleBitrate() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sent := o.bytesSent()
+	now := time.Now()
+	if !o.sampleAt.IsZero() && sent >= o.sampleBytes {
+		o.bitrateKbps = float64(sent-o.sampleBytes) * 8 / 1000 / now.Sub(o.sampleAt).Seconds()
+	}
+	o.sampleBytes = sent
+	o.sampleAt = now
+}
+
+func (o *output) stats() DestinationStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	bitrate := o.bitrateKbps
+	if o.publisher == nil {
+		bitrate = 0
+	}
+	return DestinationStats{
+		Label:           o.destination.Label,
+		State:           o.state,
+		BytesSent:       o.bytesSent(),
+		BitrateKbps:     bitrate,
+		Reconnects:      o.reconnects,
+		DroppedMessages: o.dropped,
+		LastError:       o.lastError,
+	}
+}
@@ -0,0 +1,353 @@
+package relay_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/relay"
+)
+
+// sink is an RTMP server standing in for a platform ingest. It records the
+// stream keys published to it and the messages it receives.
+type sink struct {
+	server   *relay.Server
+	address  string
+	mu       sync.Mutex
+	keys     []string
+	messages []*relay.Message
+	// stalled blocks Write until it is closed, like an ingest that stops reading
+	stalled chan struct{}
+}
+
+func startSink(address string) *sink {
+	listener, err := net.Listen("tcp", address)
+	Expect(err).NotTo(HaveOccurred())
+	s := &sink{address: listener.Addr().String()}
+	s.server = &relay.Server{OnPublish: func(app string, streamKey string) (relay.Publication, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.keys = append(s.keys, app+"/"+streamKey)
+		return s, nil
+	}}
+	go s.server.Serve(listener)
+	DeferCleanup(s.server.Close)
+	return s
+}
+
+func (s *sink) Write(message *relay.Message) {
+	if s.stalled != nil {
+		<-s.stalled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func (s *sink) Close() {}
+
+func (s *sink) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.keys...)
+}
+
+// Messages returns the payloads received so far.
+func (s *sink) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var payloads []string
+	for _, message := range s.messages {
+		payloads = append(payloads, string(message.Payload))
+	}
+	return payloads
+}
+
+// VideoTimestamps returns the timestamps of the video messages received so
+// far.
+func (s *sink) VideoTimestamps() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var timestamps []uint32
+	for _, message := range s.messages {
+		if message.Type == relay.MessageVideo {
+			timestamps = append(timestamps, message.Timestamp)
+		}
+	}
+	return timestamps
+}
+
+func (s *sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// FLV tags a publisher sends: an AVC and an AAC sequence header, keyframes
+// and inter frames.
+var (
+	metadata    = &relay.Message{Type: relay.MessageData, Payload: []byte("metadata")}
+	videoHeader = &relay.Message{Type: relay.MessageVideo, Payload: []byte{0x17, 0x00, 'h'}}
+	audioHeader = &relay.Message{Type: relay.MessageAudio, Payload: []byte{0xaf, 0x00, 'a'}}
+	keyframe    = &relay.Message{Type: relay.MessageVideo, Payload: []byte{0x17, 0x01, 'k'}}
+	interFrame  = &relay.Message{Type: relay.MessageVideo, Payload: []byte{0x27, 0x01, 'i'}}
+)
+
+var _ = Describe("RTMP relay", func() {
+	var (
+		rtmpRelay *relay.Relay
+		relayURL  string
+		twitch    *sink
+		youtube   *sink
+		statesMu  sync.Mutex
+		states    []string
+	)
+
+	BeforeEach(func() {
+		twitch = startSink("127.0.0.1:0")
+		youtube = startSink("127.0.0.1:0")
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		rtmpRelay = relay.New()
+		go rtmpRelay.Serve(listener)
+		DeferCleanup(rtmpRelay.Close)
+		relayURL = "rtmp://" + listener.Addr().String() + "/live"
+
+		states = nil
+		rtmpRelay.Register("session-1", []relay.Destination{
+			{Label: "twitch", URL: "rtmp://" + twitch.address + "/app", Key: "live_123"},
+			{Label: "youtube", URL: "rtmp://" + youtube.address + "/live2/abcd-efgh"},
+		}, 0, func(label string, state string, message string) {
+			statesMu.Lock()
+			defer statesMu.Unlock()
+			states = append(states, label+" "+state)
+		})
+	})
+
+	// publishFrames streams a keyframe every ten frames until the test ends
+	// or the returned function disconnects the publisher.
+	publishFrames := func(streamKey string, keyframe *relay.Message, interFrame *relay.Message) func() {
+		publisher, err := relay.Dial(relayURL, streamKey, 5*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		for _, message := range []*relay.Message{metadata, videoHeader, audioHeader} {
+			Expect(publisher.WriteMessage(message, time.Second)).To(Succeed())
+		}
+
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			for frame := 0; ; frame++ {
+				message := interFrame
+				if frame%10 == 0 {
+					message = keyframe
+				}
+				message = &relay.Message{Type: message.Type, Timestamp: uint32(frame * 40), Payload: message.Payload}
+				if publisher.WriteMessage(message, time.Second) != nil {
+					return
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(20 * time.Millisecond):
+				}
+			}
+		}()
+		var stopOnce sync.Once
+		stop := func() {
+			stopOnce.Do(func() {
+				close(done)
+				<-stopped
+				publisher.Close()
+			})
+		}
+		DeferCleanup(stop)
+		return stop
+	}
+
+	publish := func(streamKey string) {
+		publishFrames(streamKey, keyframe, interFrame)
+	}
+
+	It("should push the stream to every destination", func() {
+		publish("session-1")
+
+		Eventually(twitch.Keys, 5*time.Second).Should(Equal([]string{"app/live_123"}))
+		Eventually(youtube.Keys, 5*time.Second).Should(Equal([]string{"live2/abcd-efgh"}))
+		Eventually(twitch.Messages, 5*time.Second).Should(ContainElement("\x17\x01k"))
+		Expect(twitch.Messages()[:3]).To(Equal([]string{"metadata", "\x17\x00h", "\xaf\x00a"}))
+
+		Eventually(func() []relay.DestinationStats { return rtmpRelay.Stats("session-1") }, 5*time.Second).Should(
+			HaveEach(And(
+				HaveField("State", relay.StateLive),
+				HaveField("BytesSent", BeNumerically(">", 0)),
+			)))
+	})
+
+	It("should reconnect a destination that drops without affecting the others", func() {
+		publish("session-1")
+		Eventually(youtube.Messages, 5*time.Second).Should(ContainElement("\x17\x01k"))
+
+		// The platform drops the connection and comes back on the same address
+		youtube.server.Close()
+		restarted := startSink(youtube.address)
+		twitch.Reset()
+
+		Eventually(restarted.Keys, 10*time.Second).Should(Equal([]string{"live2/abcd-efgh"}))
+		Eventually(restarted.Messages, 5*time.Second).Should(ContainElement("\x17\x01k"))
+		Expect(restarted.Messages()[:4]).To(Equal([]string{"metadata", "\x17\x00h", "\xaf\x00a", "\x17\x01k"}))
+		Expect(twitch.Messages()).NotTo(BeEmpty())
+
+		stats := rtmpRelay.Stats("session-1")
+		Expect(stats[0].Reconnects).To(Equal(0))
+		Expect(stats[1].Reconnects).To(BeNumerically(">=", 1))
+		statesMu.Lock()
+		defer statesMu.Unlock()
+		Expect(states).To(ContainElements("youtube reconnecting", "youtube live"))
+	})
+
+	It("should carry the timestamps on when the publisher reconnects", func() {
+		disconnect := publishFrames("session-1", keyframe, interFrame)
+		Eventually(twitch.VideoTimestamps, 5*time.Second).Should(ContainElement(BeNumerically(">=", 400)))
+		disconnect()
+
+		publishFrames("session-1",
+			&relay.Message{Type: relay.MessageVideo, Payload: []byte{0x17, 0x01, 'K'}},
+			&relay.Message{Type: relay.MessageVideo, Payload: []byte{0x27, 0x01, 'I'}})
+		Eventually(twitch.Messages, 5*time.Second).Should(ContainElement("\x27\x01I"))
+
+		timestamps := twitch.VideoTimestamps()
+		for i := 1; i < len(timestamps); i++ {
+			Expect(timestamps[i]).To(BeNumerically(">=", timestamps[i-1]))
+		}
+		// The new publisher's headers go out again ahead of its first keyframe
+		messages := twitch.Messages()
+		first := 0
+		for messages[first] != "\x17\x01K" {
+			first++
+		}
+		Expect(messages[first-3 : first]).To(Equal([]string{"metadata", "\x17\x00h", "\xaf\x00a"}))
+		Expect(twitch.Keys()).To(HaveLen(1))
+		Expect(rtmpRelay.Stats("session-1")[0].Reconnects).To(Equal(0))
+	})
+
+	It("should report stats while a destination stalls", func() {
+		twitch.stalled = make(chan struct{})
+		DeferCleanup(func() { close(twitch.stalled) })
+		large := make([]byte, 512*1024)
+		publishFrames("session-1", &relay.Message{Type: relay.MessageVideo, Payload: append([]byte{0x17, 0x01}, large...)}, &relay.Message{Type: relay.MessageVideo, Payload: append([]byte{0x27, 0x01}, large...)})
+		Eventually(youtube.Messages, 5*time.Second).Should(ContainElement(HavePrefix("\x17\x01")))
+		// Long enough for the writes to twitch to fill the socket buffers and block
+		time.Sleep(time.Second)
+
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			rtmpRelay.Stats("session-1")
+			Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+
+	It("should hold a delayed stream and drop the buffer on dump", func() {
+		delayed := startSink("127.0.0.1:0")
+		rtmpRelay.Register("session-2", []relay.Destination{
+			{Label: "delayed", URL: "rtmp://" + delayed.address + "/app", Key: "live_456"},
+		}, time.Second, nil)
+		started := time.Now()
+		publish("session-2")
+
+		Eventually(delayed.Messages, 5*time.Second).Should(ContainElement("\x17\x01k"))
+		Expect(time.Since(started)).To(BeNumerically(">=", time.Second))
+
+		// The buffered second is dropped and so are the frames encoded before
+		// the slate went up, then the slate goes out without delay
+		Expect(rtmpRelay.Dump("session-2")).To(Succeed())
+		time.Sleep(100 * time.Millisecond)
+		delayed.Reset()
+		Consistently(delayed.Messages, 2500*time.Millisecond).Should(BeEmpty())
+		Eventually(delayed.Messages, 5*time.Second).ShouldNot(BeEmpty())
+		Expect(delayed.Messages()[0]).To(Equal("\x17\x01k"))
+
+		// While the buffer refills only the slate keyframe is repeated
+		Expect(rtmpRelay.Resume("session-2")).To(Succeed())
+		time.Sleep(100 * time.Millisecond)
+		delayed.Reset()
+		Consistently(delayed.Messages, 700*time.Millisecond).ShouldNot(ContainElement("\x27\x01i"))
+		Expect(delayed.Messages()).To(ContainElement("\x17\x01k"))
+		Eventually(delayed.Messages, 5*time.Second).Should(ContainElement("\x27\x01i"))
+
+		timestamps := delayed.VideoTimestamps()
+		for i := 1; i < len(timestamps); i++ {
+			Expect(timestamps[i]).To(BeNumerically(">=", timestamps[i-1]))
+		}
+	})
+
+	It("should switch to a standby publisher without reconnecting the destinations", func() {
+		publish("session-1")
+		Eventually(twitch.Messages, 5*time.Second).Should(ContainElement("\x17\x01k"))
+
+		Expect(rtmpRelay.AddStandby("session-1", "session-1-standby")).To(Succeed())
+		publishFrames("session-1-standby",
+			&relay.Message{Type: relay.MessageVideo, Payload: []byte{0x17, 0x01, 'K'}},
+			&relay.Message{Type: relay.MessageVideo, Payload: []byte{0x27, 0x01, 'I'}})
+		Consistently(twitch.Messages, 300*time.Millisecond).ShouldNot(ContainElement("\x27\x01I"))
+
+		Expect(rtmpRelay.Switch("session-1", "session-1-standby", 5*time.Second)).To(Succeed())
+		time.Sleep(100 * time.Millisecond)
+		Expect(twitch.Messages()).To(ContainElement("\x17\x01K"))
+		timestamps := twitch.VideoTimestamps()
+		for i := 1; i < len(timestamps); i++ {
+			Expect(timestamps[i]).To(BeNumerically(">=", timestamps[i-1]))
+		}
+
+		// The old publisher is still connected but no longer reaches the destinations
+		twitch.Reset()
+		Eventually(twitch.Messages, 5*time.Second).Should(ContainElement("\x27\x01I"))
+		Consistently(twitch.Messages, 500*time.Millisecond).ShouldNot(ContainElement("\x27\x01i"))
+		Expect(twitch.Keys()).To(HaveLen(1))
+		Expect(rtmpRelay.Stats("session-1")[0].Reconnects).To(Equal(0))
+	})
+
+	It("should keep the old publisher when the standby is not live", func() {
+		publish("session-1")
+		Expect(rtmpRelay.AddStandby("session-1", "session-1-standby")).To(Succeed())
+
+		Expect(rtmpRelay.Switch("session-1", "session-1-standby", 200*time.Millisecond)).To(MatchError(relay.ErrSourceNotLive))
+		twitch.Reset()
+		Eventually(twitch.Messages, 5*time.Second).Should(ContainElement("\x27\x01i"))
+
+		// The failed standby is forgotten, the active publisher's key is kept
+		Expect(rtmpRelay.RemoveStandby("session-1", "session-1-standby")).To(Succeed())
+		_, err := relay.Dial(relayURL, "session-1-standby", 5*time.Second)
+		Expect(err).To(MatchError(ContainSubstring("NetStream.Publish.BadName")))
+		Expect(rtmpRelay.Switch("session-1", "session-1-standby", time.Millisecond)).To(MatchError(relay.ErrUnknownStream))
+		Expect(rtmpRelay.RemoveStandby("session-1", "session-1")).To(Succeed())
+		Expect(rtmpRelay.Stats("session-1")).To(HaveLen(2))
+	})
+
+	It("should only dump delayed streams", func() {
+		Expect(rtmpRelay.Dump("session-1")).To(MatchError(relay.ErrNoDelay))
+		Expect(rtmpRelay.Dump("unknown")).To(MatchError(relay.ErrUnknownStream))
+	})
+
+	It("should reject stream keys that are not registered", func() {
+		_, err := relay.Dial(relayURL, "unknown", 5*time.Second)
+		Expect(err).To(MatchError(ContainSubstring("NetStream.Publish.BadName")))
+	})
+
+	It("should split the stream name from ingest URLs", func() {
+		address, app, stream, useTLS, err := relay.SplitURL("rtmps://live-api-s.facebook.com/rtmp/FB-123", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect([]interface{}{address, app, stream, useTLS}).To(Equal([]interface{}{"live-api-s.facebook.com:443", "rtmp", "FB-123", true}))
+	})
+})
+
+func TestRelay(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Relay Suite")
+}
@@ -0,0 +1,202 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const publishStreamID = 1
+
+// Publication receives the media of a stream while it is being published.
+type Publication interface {
+	Write(message *Message)
+	Close()
+}
+
+// Server accepts RTMP publishers. OnPublish is called when a client starts
+// publishing a stream and returns where its messages go; an error rejects
+// the stream.
+type Server struct {
+	OnPublish func(app string, streamKey string) (Publication, error)
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*conn]bool
+	closed   bool
+}
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("rtmp: server closed")
+
+// Serve accepts connections on the listener until Close is called.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.conns = map[*conn]bool{}
+	s.mu.Unlock()
+
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.handle(netConn)
+	}
+}
+
+// Close stops accepting publishers and disconnects the current ones.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) track(c *conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		s.conns[c] = true
+	} else {
+		delete(s.conns, c)
+	}
+	return true
+}
+
+func (s *Server) handle(netConn net.Conn) {
+	c := newConn(netConn)
+	defer c.Close()
+	if !s.track(c, true) {
+		return
+	}
+	defer s.track(c, false)
+
+	netConn.SetDeadline(time.Now().Add(10 * time.Second))
+	err := c.serverHandshake()
+	if err != nil {
+		log.Printf("RTMP relay: %s: %v", netConn.RemoteAddr(), err)
+		return
+	}
+	netConn.SetDeadline(time.Time{})
+
+	var publication Publication
+	defer func() {
+		if publication != nil {
+			publication.Close()
+		}
+	}()
+
+	app := ""
+	for {
+		message, err := c.readMessage()
+		if err != nil {
+			return
+		}
+		switch message.Type {
+		case MessageAudio, MessageVideo, MessageData:
+			if publication != nil {
+				publication.Write(message)
+			}
+		case msgCommandAMF0, msgCommandAMF3:
+			payload := message.Payload
+			if message.Type == msgCommandAMF3 && len(payload) > 0 {
+				payload = payload[1:]
+			}
+			values, err := amfDecode(payload)
+			if err != nil || len(values) < 2 {
+				continue
+			}
+			name, _ := values[0].(string)
+			transactionID, _ := values[1].(float64)
+			switch name {
+			case "connect":
+				if object, ok := commandArg(values, 2).(amfObj); ok {
+					app, _ = object["app"].(string)
+				}
+				err = s.acceptConnect(c, transactionID)
+			case "createStream":
+				err = c.writeCommand(0, "_result", transactionID, nil, publishStreamID)
+			case "releaseStream", "FCPublish":
+				if transactionID != 0 {
+					err = c.writeCommand(0, "_result", transactionID, nil, nil)
+				}
+			case "publish":
+				streamKey, _ := commandArg(values, 3).(string)
+				publication, err = s.OnPublish(app, streamKey)
+				if err != nil {
+					c.writeCommand(message.StreamID, "onStatus", 0, nil, amfObj{
+						"level":       "error",
+						"code":        "NetStream.Publish.BadName",
+						"description": err.Error(),
+					})
+					return
+				}
+				err = c.writeCommand(message.StreamID, "onStatus", 0, nil, amfObj{
+					"level":       "status",
+					"code":        "NetStream.Publish.Start",
+					"description": fmt.Sprintf("Publishing %s.", streamKey),
+				})
+			case "FCUnpublish", "deleteStream", "closeStream":
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) acceptConnect(c *conn, transactionID float64) error {
+	if err := c.writeControl(msgWindowAckSize, windowAckSize); err != nil {
+		return err
+	}
+	// Dynamic peer bandwidth limit
+	err := c.writeMessage(&Message{Type: msgSetPeerBandwidth, Payload: []byte{0x00, 0x26, 0x25, 0xa0, 2}})
+	if err != nil {
+		return err
+	}
+	if err := c.setChunkSize(outgoingChunkSize); err != nil {
+		return err
+	}
+	return c.writeCommand(0, "_result", transactionID,
+		amfObj{"fmsVer": "FMS/3,0,1,123", "capabilities": 31},
+		amfObj{
+			"level":          "status",
+			"code":           "NetConnection.Connect.Success",
+			"description":    "Connection succeeded.",
+			"objectEncoding": 0,
+		})
+}
+
+func commandArg(values []interface{}, index int) interface{} {
+	if index >= len(values) {
+		return nil
+	}
+	return values[index]
+}
@@ -0,0 +1,211 @@
+package relay
+
+import (
+	"errors"
+	"time"
+)
+
+// switchGap is the timestamp step between the last message of the old
+// source and the first keyframe of the new one.
+const switchGap = 40
+
+// ErrSourceNotLive is returned when a standby source sends no keyframe
+// before the switch times out.
+var ErrSourceNotLive = errors.New("standby source is not live")
+
+// source is a publisher of a route, known by its stream key. A route starts
+// with one and gets more as standbys: a replacement publisher connects under
+// its own key while the active one keeps the destinations fed, and takes
+// over at its first keyframe after a switch.
+//
+// The fields are guarded by the route's mutex.
+type source struct {
+	route       *route
+	key         string
+	offset      int64
+	rejoining   bool
+	metadata    *Message
+	videoHeader *Message
+	audioHeader *Message
+}
+
+// addSource is called with the relay's lock held, before the route can be
+// published to or while it is.
+func (rt *route) addSource(key string) *source {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	src := &source{route: rt, key: key}
+	rt.sources[key] = src
+	return src
+}
+
+// removeSource is called with the relay's lock held. It reports whether the
+// source was removed, which it is not while it is active.
+func (rt *route) removeSource(key string) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	src := rt.sources[key]
+	if src == nil || src == rt.active {
+		return false
+	}
+	if rt.pending == src {
+		rt.pending = nil
+	}
+	delete(rt.sources, key)
+	return true
+}
+
+func (rt *route) source(key string) *source {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.sources[key]
+}
+
+func (rt *route) sourceKeys() []string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var keys []string
+	for key := range rt.sources {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Write is called with each message of the publisher. A standby source only
+// keeps its headers, until a pending switch to it sees a keyframe.
+func (src *source) Write(message *Message) {
+	rt := src.route
+	rt.mu.Lock()
+	switch {
+	case message.Type == MessageData:
+		src.metadata = message
+	case isVideoSequenceHeader(message):
+		src.videoHeader = message
+	case isAudioSequenceHeader(message):
+		src.audioHeader = message
+	}
+
+	if rt.active == src && src.rejoining {
+		// The publisher came back, its headers wait for its first frame
+		if !isMediaFrame(message) {
+			rt.mu.Unlock()
+			return
+		}
+		src.rejoining = false
+		rt.deliver(rt.carryOn(src, message)...)
+		return
+	}
+	if rt.active == src {
+		rt.deliver(rt.shift(src, message))
+		return
+	}
+	if rt.pending != src || !isKeyframe(message) || isVideoSequenceHeader(message) {
+		rt.mu.Unlock()
+		return
+	}
+
+	rt.active = src
+	rt.pending = nil
+	close(rt.switched)
+	rt.deliver(rt.carryOn(src, message)...)
+}
+
+// carryOn puts the first frame of a source that takes over on the route's
+// timeline after the last message sent, and its headers ahead of it. Called
+// with the lock held.
+func (rt *route) carryOn(src *source, message *Message) []*Message {
+	src.offset = rt.lastTimestamp + switchGap - int64(message.Timestamp)
+	first := rt.shift(src, message)
+	var messages []*Message
+	for _, header := range src.headers() {
+		out := *header
+		out.Timestamp = first.Timestamp
+		messages = append(messages, &out)
+	}
+	return append(messages, first)
+}
+
+// rejoin is called when a publisher connects. When the active source comes
+// back, the new publisher's timestamps start again at zero, so they are
+// moved on from where the stream left off.
+func (rt *route) rejoin(src *source) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.active == src && rt.live {
+		src.rejoining = true
+	}
+}
+
+// Close is called when the publisher leaves. The destinations stay connected
+// for its return.
+func (src *source) Close() {}
+
+func (src *source) headers() []*Message {
+	var headers []*Message
+	for _, header := range []*Message{src.metadata, src.videoHeader, src.audioHeader} {
+		if header != nil {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// shift moves a message of the active source onto the route's timeline.
+// Called with the lock held.
+func (rt *route) shift(src *source, message *Message) *Message {
+	out := message
+	if src.offset != 0 {
+		shifted := *message
+		timestamp := int64(message.Timestamp) + src.offset
+		if timestamp < 0 {
+			timestamp = 0
+		}
+		shifted.Timestamp = uint32(timestamp)
+		out = &shifted
+	}
+	if out.Type == MessageAudio || out.Type == MessageVideo {
+		rt.live = true
+		if int64(out.Timestamp) > rt.lastTimestamp {
+			rt.lastTimestamp = int64(out.Timestamp)
+		}
+	}
+	return out
+}
+
+// isMediaFrame tells audio and video frames from sequence headers and
+// metadata.
+func isMediaFrame(message *Message) bool {
+	return (message.Type == MessageAudio || message.Type == MessageVideo) && !isVideoSequenceHeader(message) && !isAudioSequenceHeader(message)
+}
+
+// switchTo makes a standby source active at its next keyframe and waits for
+// it.
+func (rt *route) switchTo(src *source, timeout time.Duration) error {
+	rt.mu.Lock()
+	if rt.active == src {
+		rt.mu.Unlock()
+		return nil
+	}
+	if rt.pending != src {
+		rt.pending = src
+		rt.switched = make(chan struct{})
+	}
+	switched := rt.switched
+	rt.mu.Unlock()
+
+	select {
+	case <-switched:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.active == src {
+		return nil
+	}
+	if rt.pending == src {
+		rt.pending = nil
+	}
+	return ErrSourceNotLive
+}
@@ -1,7 +1,10 @@
 package repositories
 
 import (
+	"encoding/json"
+
 	"spoutbreeze/initializers"
+	"spoutbreeze/models"
 )
 
 func StoreRTMPURL(rtmpURL string) error {
@@ -10,4 +13,24 @@ func StoreRTMPURL(rtmpURL string) error {
 
 func StoreStreamKey(streamKEY string) error {
 	return initializers.RedisClient.Set(initializers.RedisContext, "twitch_stream_key", streamKEY, 0).Err()
-}
\ No newline at end of file
+}
+
+func GetTenantProfile(tenantID string) (*models.TenantProfile, error) {
+	data, err := initializers.RedisClient.Get(initializers.RedisContext, "tenant_profile:"+tenantID).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var profile models.TenantProfile
+	err = json.Unmarshal(data, &profile)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetDestinationStatuses returns the per-destination status the capture
+// container reports for a session, keyed by destination label.
+func GetDestinationStatuses(sessionID string) (map[string]string, error) {
+	return initializers.RedisClient.HGetAll(initializers.RedisContext, "session:"+sessionID+":destinations").Result()
+}
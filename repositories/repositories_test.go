@@ -67,6 +67,16 @@ var _ = Describe("Redis Repository", func() {
 		})
 	})
 
+	Describe("GetTenantProfile", func() {
+		Context("when Redis client is not initialized", func() {
+			It("should panic with nil client", func() {
+				Expect(func() {
+					repositories.GetTenantProfile("tenant-1")
+				}).To(Panic())
+			})
+		})
+	})
+
 	Describe("Function signatures", func() {
 		It("should have correct StoreRTMPURL function signature", func() {
 
@@ -11,6 +11,15 @@ func SetupRouter() *gin.Engine {
 	broadcasterGroup := router.Group("/broadcaster")
 	{
 		broadcasterGroup.POST("/joinBBB", controllers.JoinBBB)
+		broadcasterGroup.POST("/validate", controllers.ValidateBroadcast)
+		broadcasterGroup.POST("/test", controllers.StartTestBroadcast)
+		broadcasterGroup.GET("/sessions/:id", controllers.GetSession)
+		broadcasterGroup.GET("/sessions/:id/recordings", controllers.ListRecordings)
+		broadcasterGroup.GET("/sessions/:id/recordings/:name", controllers.DownloadRecording)
+		broadcasterGroup.PATCH("/sessions/:id/overlays/:overlay_id", controllers.UpdateOverlay)
+		broadcasterGroup.POST("/sessions/:id/layout", controllers.ApplyLayoutAction)
+		broadcasterGroup.POST("/sessions/:id/delay", controllers.ApplyDelayAction)
+		broadcasterGroup.POST("/sessions/:id/restart", controllers.RestartSession)
 	}
 
 	healthController := controllers.NewHealthController()
@@ -1,6 +1,7 @@
 package routes_test
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -60,6 +61,104 @@ var _ = Describe("Routes", func() {
 				Expect(w.Code).To(Equal(http.StatusOK))
 			})
 
+			It("should return 404 for unknown broadcaster sessions", func() {
+				req, err := http.NewRequest("GET", "/broadcaster/sessions/unknown", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+				Expect(w.Body.String()).To(ContainSubstring("session not found"))
+			})
+
+			It("should return 404 when updating overlays of unknown sessions", func() {
+				req, err := http.NewRequest("PATCH", "/broadcaster/sessions/unknown/overlays/title", bytes.NewBufferString(`{"text":"Lecture 2"}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("should return 404 when changing the layout of unknown sessions", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/sessions/unknown/layout", bytes.NewBufferString(`{"action":"smart_layout"}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("should return 404 when dumping the delay of unknown sessions", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/sessions/unknown/delay", bytes.NewBufferString(`{"action":"dump"}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("should return 404 when restarting unknown sessions", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/sessions/unknown/restart", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("should return 404 for recordings of unknown sessions", func() {
+				req, err := http.NewRequest("GET", "/broadcaster/sessions/unknown/recordings", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusNotFound))
+			})
+
+			It("should reject unknown layout actions", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/sessions/unknown/layout", bytes.NewBufferString(`{"action":"spin"}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("should reject invalid preflight requests", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/validate", bytes.NewBufferString(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("should reject test broadcasts that run too long", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/test", bytes.NewBufferString(`{"rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","duration_seconds":3600}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			It("should reject unknown quality profiles", func() {
+				req, err := http.NewRequest("POST", "/broadcaster/test", bytes.NewBufferString(`{"rtmp_url":"rtmp://streaming.example.com/live","stream_key":"stream-123","quality":"4k"}`))
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("Content-Type", "application/json")
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			})
+
 			It("should return 404 for undefined routes", func() {
 				req, err := http.NewRequest("GET", "/undefined", nil)
 				Expect(err).NotTo(HaveOccurred())
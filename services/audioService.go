@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+// audioOnlyChromeArgs drop GPU work, images and background activity that an
+// audio-only bot does not need.
+var audioOnlyChromeArgs = []string{
+	"--disable-gpu",
+	"--disable-software-rasterizer",
+	"--disable-extensions",
+	"--disable-background-networking",
+	"--blink-settings=imagesEnabled=false",
+	"--renderer-process-limit=2",
+	"--js-flags=--max-old-space-size=256",
+}
+
+// audioOnlyScript keeps the BBB client from drawing video. Incoming webcam and
+// screenshare tracks are disabled and paused as they appear, the meeting audio
+// plays on.
+const audioOnlyScript = `
+var css = "video, canvas, svg image, img { display: none !important; }";
+if (!document.getElementById("spoutbreeze-audio-only")) {
+	var style = document.createElement("style");
+	style.id = "spoutbreeze-audio-only";
+	style.textContent = css;
+	document.head.appendChild(style);
+}
+var pause = function () {
+	document.querySelectorAll("video").forEach(function (video) {
+		if (video.srcObject) {
+			video.srcObject.getVideoTracks().forEach(function (track) {
+				track.enabled = false;
+			});
+		}
+		if (!video.paused) {
+			video.pause();
+		}
+	});
+};
+pause();
+if (!window.__spoutbreezeAudioOnlyObserver) {
+	window.__spoutbreezeAudioOnlyObserver = new MutationObserver(pause);
+	window.__spoutbreezeAudioOnlyObserver.observe(document.body, { childList: true, subtree: true });
+}
+`
+
+// Containers and content types for audio sent to Icecast mounts.
+var icecastFormats = map[string]struct {
+	Format      string
+	ContentType string
+}{
+	models.AudioCodecAAC:  {Format: "adts", ContentType: "audio/aac"},
+	models.AudioCodecMP3:  {Format: "mp3", ContentType: "audio/mpeg"},
+	models.AudioCodecOpus: {Format: "ogg", ContentType: "audio/ogg"},
+}
+
+func isIcecast(destination models.Destination) bool {
+	switch DestinationProtocol(destination) {
+	case models.ProtocolIcecast, models.ProtocolHTTP, models.ProtocolHTTPS:
+		return true
+	}
+	return false
+}
+
+func applyAudioOnly(driver selenium.WebDriver) {
+	_, err := driver.ExecuteScript(audioOnlyScript, nil)
+	if err != nil {
+		log.Printf("Warning: Failed to turn off video rendering: %v", err)
+	}
+}
+
+// icecastURL adds the stream key to an Icecast mount URL as the source
+// password, unless the URL already carries credentials.
+func icecastURL(destination models.Destination) string {
+	parsed, err := url.Parse(destination.URL)
+	if err != nil || destination.Key == "" || parsed.User != nil {
+		return destination.URL
+	}
+	parsed.User = url.UserPassword("source", destination.Key)
+	return parsed.String()
+}
+
+// validateAudio checks the audio settings against the destinations. Icecast
+// mounts only take audio, and FLV over RTMP does not carry Opus.
+func validateAudio(profile QualityProfile, destinations []models.Destination) error {
+	if profile.AudioCodec != models.AudioCodecAAC && !profile.AudioOnly {
+		return fmt.Errorf("%w: the %s audio codec needs the audio_only quality profile", ErrInvalidRequest, profile.AudioCodec)
+	}
+	for _, destination := range destinations {
+		protocol := DestinationProtocol(destination)
+		if isIcecast(destination) && !profile.AudioOnly {
+			return fmt.Errorf("%w: destination %q is an Icecast mount, which needs the audio_only quality profile", ErrInvalidRequest, destination.Label)
+		}
+		if profile.AudioCodec == models.AudioCodecOpus && (protocol == models.ProtocolRTMP || protocol == models.ProtocolRTMPS) {
+			return fmt.Errorf("%w: destination %q is RTMP, which does not carry Opus audio", ErrInvalidRequest, destination.Label)
+		}
+	}
+	return nil
+}
@@ -1,8 +1,8 @@
 package services
 
 import (
+	"errors"
 	"log"
-	"testing"
 	"time"
 	"os"
 	"net/http"
@@ -13,11 +13,18 @@ import (
 
 	"github.com/tebeka/selenium"
 	"github.com/sheva0914/selenium/chrome"
+	seleniumlog "github.com/tebeka/selenium/log"
 	"spoutbreeze/models"
 	// "spoutbreeze/repositories"
 )
 
 func ProcessBroadcasterRequest(request *models.BroadcasterRequest) error {
+	_, err := StartBroadcasterSession(request)
+	return err
+}
+
+// StartBroadcasterSession registers a new session and launches the bot for it in the background.
+func StartBroadcasterSession(request *models.BroadcasterRequest) (*Session, error) {
 	// Store RTMP URL and Stream URL in Redis
 	// err := repositories.StoreRTMPURL(request.RTMPURL)
 	// if err != nil {
@@ -28,43 +35,151 @@ func ProcessBroadcasterRequest(request *models.BroadcasterRequest) error {
 	// if err != nil {
 	// 	return err
 	// }
+
+	log.Printf("Starting broadcaster session for %s", request.BBBServerURL)
+
+	err := prepareDestinations(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(request.Overlays) == 0 && request.TenantID != "" {
+		overlays, err := tenantOverlays(request.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		request.Overlays = overlays
+	}
+
+	// Portrait destinations get a bot of their own
+	group := newSessionGroup(request, models.SessionTypeBroadcast)
 	
 	// Launch selenium script in the background
-	go launchSeleniumScript(request.BBBServerURL,request.BBBHealthCheckURL, request.RTMPURL, request.StreamKey)
+	for _, session := range group {
+		go launchSeleniumScript(session)
+	}
 	
-	return nil
+	return group[0], nil
 }
 
-func launchSeleniumScript(bbbURL string ,BBBHealthCheckURL string,rtmp_url string, stream_key string) {
-	StreamBBBSession(nil, bbbURL, BBBHealthCheckURL, rtmp_url, stream_key)
+func launchSeleniumScript(session *Session) {
+	stream := StreamBBBSession
+	if session.Type == models.SessionTypeTest {
+		stream = StreamTestSession
+	}
+	err := stream(session)
+	if err != nil {
+		log.Printf("Session %s failed: %v", session.ID, err)
+		reason := err.Error()
+		var sessionErr *SessionError
+		if errors.As(err, &sessionErr) {
+			reason = sessionErr.Reason
+			session.AddEvent("error", err.Error())
+		}
+		session.SetState(models.SessionStateFailed, reason)
+	}
 }
 
-func StreamBBBSession(t *testing.T, BBB_URL string, BBBHealthCheckURL string, rtmp_url string, stream_key string) {
+func StreamBBBSession(session *Session) error {
+	driver, err := startBotBrowser(session, session.relayKey)
+	if err != nil {
+		return err
+	}
+	defer fetchMoonVideos(session)
+	// A restart replaces the browser
+	defer func() { driver.Quit() }()
+	session.setDriver(driver)
+	defer session.setDriver(nil)
+	defer stopWHIP(session)
+	defer stopRelay(session)
+	defer session.stopRecording()
+
+	// Collect console and network errors for the lifetime of the session
+	stopBrowserLogs := watchBrowserLogs(driver, session)
+	defer func() { stopBrowserLogs() }()
+
+	// Keep the starting slate on the stream until the meeting is running
+	err = waitForMeetingStart(driver, session)
+	if err != nil {
+		return err
+	}
+
+	// err = driver.MaximizeWindow("")
+    // if err != nil {
+    //     log.Printf("Warning: Failed to maximize window: %v", err)
+    //     // Alternative approach if maximize doesn't work
+    //     _, err = driver.ExecuteScript("window.resizeTo(screen.width, screen.height);", nil)
+    //     if err != nil {
+    //         log.Printf("Warning: Failed to resize window with JavaScript: %v", err)
+    //     }
+    // }
+	
+	err = joinMeeting(driver, session)
+	if err != nil {
+		return err
+	}
+	revealMeeting(driver, session)
+
+	sessionID := driver.SessionID()
+	if sessionID == "" {
+		return fmt.Errorf("failed to retrieve session ID")
+	}
+	session.AddEvent("browser_started", "Moon session "+sessionID)
+	session.SetState(models.SessionStateLive, "")
+
+	// Wait for the session to end, replacing the browser when a restart is requested
+	for {
+		err = monitorMeeting(driver, session)
+		if !errors.Is(err, errRestartRequested) {
+			return err
+		}
+		replacement, err := restartBotBrowser(session, driver)
+		session.finishRestart()
+		if err != nil {
+			log.Printf("Restart of session %s failed, keeping the current browser: %v", session.ID, err)
+			continue
+		}
+		stopBrowserLogs()
+		driver = replacement
+		stopBrowserLogs = watchBrowserLogs(driver, session)
+	}
+}
+
+// startBotBrowser starts the bot's Chrome on Moon. The capture container
+// next to it gets the session's destinations through its environment, and
+// pushes to the RTMP relay under relayKey.
+func startBotBrowser(session *Session, relayKey string) (selenium.WebDriver, error) {
+	BBBHealthCheckURL := session.Request.BBBHealthCheckURL
+	quality := requestQuality(session.Request)
 
-	// Get environment variables for Selenium hub URL
-    minikubeIP := os.Getenv("CLUSTER_IP")
-    moonPort := os.Getenv("MOON_PORT_4444")
 	RedisPassword := os.Getenv("REDIS_PASSWORD")
 	// Configure Moon options with environment variables
-	moonOptions := map[string]interface{}{
-		"enableVideo": false,
-		"env": []string{"USER_REDIS_PASSWORD=" + RedisPassword,
-			"RTMP_BASE_URL=" + rtmp_url,
-			"Twitch_KEY=" + stream_key,
-			"BBBHealthCheckURL=" + BBBHealthCheckURL,
-	},
+	moonEnv := []string{"USER_REDIS_PASSWORD=" + RedisPassword,
+		"BBBHealthCheckURL=" + BBBHealthCheckURL,
+		"SESSION_TYPE=" + string(session.Type),
+		"ORIENTATION=" + orientationOf(session.Request.Orientation),
 	}
+	recordingEnv, recordingOutputs, recordingName := startRecording(session)
+	moonEnv = append(moonEnv, destinationMoonEnv(session.ID, captureDestinations(session, relayKey), quality.AudioCodec, recordingOutputs...)...)
+	moonEnv = append(moonEnv, recordingEnv...)
+	moonEnv = append(moonEnv, quality.MoonEnv()...)
+	moonOptions := debugMoonOptions(session)
+	moonOptions["screenResolution"] = quality.ScreenResolution()
+	moonOptions["env"] = moonEnv
 	// Configure Chrome options}
 	
 	chromeCaps := chrome.Capabilities{
 		ExcludeSwitches: []string{"enable-automation"},
-		Args: []string{
-			"--start-maximized",
+		Args: append(quality.ChromeArgs(),
 			"--use-fake-ui-for-media-stream",
 			"--use-fake-device-for-media-stream",
 			"--autoplay-policy=no-user-gesture-required",
 			"--audio-output-channels=2",
-		},
+		),
+		PerfLoggingPrefs: browserPerfLoggingPrefs(),
+	}
+	if len(session.Request.WHIPDestinations) > 0 {
+		chromeCaps.Args = append(chromeCaps.Args, whipCaptureArgs...)
 	}
 	
 	// Define browser capabilities
@@ -73,9 +188,25 @@ func StreamBBBSession(t *testing.T, BBB_URL string, BBBHealthCheckURL string, rt
 		"browserVersion": "0.0.1.9",
 		"moon:options":   moonOptions,
 		"goog:chromeOptions": chromeCaps,
+		seleniumlog.CapabilitiesKey: browserLoggingCapabilities(),
 	}
 
-    
+	// Connect to Moon server
+	driver, err := selenium.NewRemote(caps, seleniumHubURL())
+	if err != nil {
+		discardRecording(session, recordingName)
+		discardMoonVideo(session, moonOptions)
+		return nil, fmt.Errorf("error starting browser: %w", err)
+	}
+	return driver, nil
+}
+
+// seleniumHubURL is the Moon hub the bots are started on.
+func seleniumHubURL() string {
+	// Get environment variables for Selenium hub URL
+    minikubeIP := os.Getenv("CLUSTER_IP")
+    moonPort := os.Getenv("MOON_PORT_4444")
+
     // Use default values if environment variables are not set
     if minikubeIP == "" {
         log.Println("CLUSTER_IP environment variable not set. Using default:", minikubeIP)
@@ -83,35 +214,45 @@ func StreamBBBSession(t *testing.T, BBB_URL string, BBBHealthCheckURL string, rt
     if moonPort == "" {
         log.Println("MOON_PORT_4444 environment variable not set. Using default:", moonPort)
     }
-    
+
     // Construct the Selenium hub URL
-    seleniumHubURL := fmt.Sprintf("http://%s:%s/wd/hub", minikubeIP, moonPort)
+    return fmt.Sprintf("http://%s:%s/wd/hub", minikubeIP, moonPort)
+}
 
-	// Connect to Moon server
-	driver, err := selenium.NewRemote(caps, seleniumHubURL)
-	if err != nil {
-		log.Fatalf("Error starting browser: %v", err)
+// joinMeeting opens the meeting and walks the BBB client through its join
+// dialogs. It is also used to rejoin after the bot loses the meeting.
+func joinMeeting(driver selenium.WebDriver, session *Session) error {
+	var err error
+	if session.Request.GreenlightRoomURL != "" {
+		// Go through the Greenlight landing page, which redirects into the BBB client
+		err = JoinGreenlightRoom(driver, session.Request)
+		if err != nil {
+			return err
+		}
+	} else if slate := session.coverSlate(); slate != nil {
+		// Go from the starting slate into the client without showing it load
+		err = navigateUnderSlate(driver, session.Request.BBBServerURL, *slate)
+		if err != nil {
+			return fmt.Errorf("failed to navigate to BigBlueButton: %w", err)
+		}
+	} else {
+		// Navigate to BigBlueButton URL
+		err = driver.Get(session.Request.BBBServerURL)
+		if err != nil {
+			return fmt.Errorf("failed to navigate to BigBlueButton: %w", err)
+		}
 	}
-	defer driver.Quit()
-
-	// err = driver.MaximizeWindow("")
-    // if err != nil {
-    //     log.Printf("Warning: Failed to maximize window: %v", err)
-    //     // Alternative approach if maximize doesn't work
-    //     _, err = driver.ExecuteScript("window.resizeTo(screen.width, screen.height);", nil)
-    //     if err != nil {
-    //         log.Printf("Warning: Failed to resize window with JavaScript: %v", err)
-    //     }
-    // }
 	
-	// Navigate to BigBlueButton URL
-	err = driver.Get(BBB_URL)
+	// Wait for page load
+	time.Sleep(pageLoadWait)
+	coverJoin(driver, session)
+
+	// Wait in the guest lobby until a moderator lets the bot in
+	err = WaitForGuestApproval(driver, session, guestApprovalTimeout(session.Request))
 	if err != nil {
-		log.Fatalf("Failed to navigate to BigBlueButton: %v", err)
+		return err
 	}
-	
-	// Wait for page load
-	time.Sleep(5 * time.Second)
+	coverJoin(driver, session)
 	
 	// Handle consent popup if exists
 	consentButton, err := driver.FindElement(selenium.ByCSSSelector, "button.ytp-button[aria-label='Accept all']")
@@ -119,7 +260,28 @@ func StreamBBBSession(t *testing.T, BBB_URL string, BBBHealthCheckURL string, rt
 		consentButton.Click()
 		time.Sleep(2 * time.Second)
 	}
-	
+
+	runClientJoinSteps(driver)
+	coverJoin(driver, session)
+	applyLayout(driver, session.Request)
+	applyOverlays(driver, session.Overlays())
+
+	// Replay the producer's last layout action after a rejoin
+	if action := session.LayoutAction(); action != "" {
+		err = runLayoutAction(driver, action)
+		if err != nil {
+			log.Printf("Warning: Failed to restore layout %s: %v", action, err)
+		}
+	}
+
+	// Publish to WHIP destinations once the page shows the meeting
+	startWHIP(driver, session)
+	return nil
+}
+
+// runClientJoinSteps picks listen only and closes the side panels so that
+// only the meeting content is captured. It is safe to rerun on a live client.
+func runClientJoinSteps(driver selenium.WebDriver) {
 	// Click listen only button (bigbluebutton session)
 	listenOnlyButton, err := driver.FindElement(selenium.ByCSSSelector, "button[aria-label='Listen only']")
 	if err == nil {
@@ -143,70 +305,47 @@ func StreamBBBSession(t *testing.T, BBB_URL string, BBBHealthCheckURL string, rt
 	usersAndMessagesButton, err := driver.FindElement(selenium.ByCSSSelector, "button[aria-label='Users and messages toggle']")
 	if err != nil {
 		log.Printf("Warning: Failed to find Users and messages button: %v", err)
-	} else {
+	} else if expanded, _ := usersAndMessagesButton.GetAttribute("aria-expanded"); expanded != "false" {
+		// Only click when the panel is open, the toggle would reopen it when the join steps are rerun
 		_, err = driver.ExecuteScript("arguments[0].click();", []interface{}{usersAndMessagesButton})
 		if err != nil {
 			log.Printf("Warning: Failed to click Users and messages button with JavaScript: %v", err)
 		}
 		time.Sleep(2 * time.Second)
 	}
+}
 
-	
-	// Wait for the session to end 
-	
-	sessionID := driver.SessionID()
-	if sessionID == "" {
-		log.Fatalf("Failed to retrieve session ID")
-	}
-	
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// IsMeetingRunning asks the BBB health check URL whether the meeting is
+// running. An error means the server could not be asked, not that the
+// meeting has ended. An answer from BBB that is not SUCCESS, such as
+// notFound once the meeting is gone, means it is not running.
+func IsMeetingRunning(client *http.Client, BBBHealthCheckURL string) (bool, error) {
+	resp, err := client.Get(BBBHealthCheckURL)
+	if err != nil {
+		return false, fmt.Errorf("error checking meeting status: %w", err)
 	}
+	defer resp.Body.Close()
 
-	// Function to check if meeting is running
-	isMeetingRunning := func() bool {
-		resp, err := client.Get(BBBHealthCheckURL)
-		if err != nil {
-			log.Printf("Error checking meeting status: %v", err)
-			return false
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading response body: %v", err)
-			return false
-		}
+	// A proxy error page means BBB is unreachable, not that the meeting ended
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("error checking meeting status: health check returned %s", resp.Status)
+	}
 
-		// Parse XML response
-		var response struct {
-			ReturnCode string `xml:"returncode"`
-			Running    string `xml:"running"`
-		}
-		
-		err = xml.Unmarshal(body, &response)
-		if err != nil {
-			log.Printf("Error parsing XML response: %v", err)
-			return false
-		}
-		
-		return response.ReturnCode == "SUCCESS" && response.Running == "true"
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("error reading response body: %w", err)
 	}
 
-	var meetingWasRunning bool
-	// Check session status every 20 seconds
-	for {
-		meetingRunning := isMeetingRunning()
-		if meetingRunning != meetingWasRunning {
-			if meetingRunning {
-				log.Println("Meeting is still running, keeping session alive...")
-			} else {
-				log.Println("Meeting has ended, terminating session...")
-			}
-			meetingWasRunning = meetingRunning
-		}
-		time.Sleep(20 * time.Second)
+	// Parse XML response
+	var response struct {
+		ReturnCode string `xml:"returncode"`
+		Running    string `xml:"running"`
 	}
 	
-}
\ No newline at end of file
+	err = xml.Unmarshal(body, &response)
+	if err != nil {
+		return false, fmt.Errorf("error parsing XML response: %w", err)
+	}
+	
+	return response.ReturnCode == "SUCCESS" && response.Running == "true", nil
+}
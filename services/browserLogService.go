@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/sheva0914/selenium/chrome"
+	"github.com/tebeka/selenium"
+	seleniumlog "github.com/tebeka/selenium/log"
+	"spoutbreeze/models"
+)
+
+const browserLogPollInterval = 10 * time.Second
+
+// fatalBrowserLogPatterns are BBB client errors that break the stream even
+// though the page itself keeps rendering. A closed WebSocket is not one of
+// them, every page load and rejoin closes the client's sockets.
+var fatalBrowserLogPatterns = []struct {
+	reason  string
+	pattern *regexp.Regexp
+}{
+	{"websocket_disconnected", regexp.MustCompile(`(?i)websocket connection to .* failed|Network\.webSocketFrameError`)},
+	{"sfu_failure", regexp.MustCompile(`(?i)sfu.*(error|fail|disconnect)|ice (negotiation|connection).*failed|media could not reach the server`)},
+	{"media_permission_denied", regexp.MustCompile(`(?i)NotAllowedError|NotReadableError|permission denied|could not start (audio|video) source`)},
+	{"audio_join_failed", regexp.MustCompile(`(?i)failed to join audio|audio.*call failed`)},
+}
+
+// ClassifyBrowserLog reports whether a browser log message matches a known
+// fatal BBB client error, and which one.
+func ClassifyBrowserLog(message string) (string, bool) {
+	for _, fatal := range fatalBrowserLogPatterns {
+		if fatal.pattern.MatchString(message) {
+			return fatal.reason, true
+		}
+	}
+	return "", false
+}
+
+func browserLoggingCapabilities() seleniumlog.Capabilities {
+	return seleniumlog.Capabilities{
+		seleniumlog.Browser:     seleniumlog.All,
+		seleniumlog.Performance: seleniumlog.All,
+	}
+}
+
+func browserPerfLoggingPrefs() *chrome.PerfLoggingPreferences {
+	enableNetwork := true
+	enablePage := false
+	return &chrome.PerfLoggingPreferences{
+		EnableNetwork: &enableNetwork,
+		EnablePage:    &enablePage,
+	}
+}
+
+// watchBrowserLogs polls the browser and performance logs until the returned
+// stop function is called.
+func watchBrowserLogs(driver selenium.WebDriver, session *Session) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(browserLogPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				collectBrowserLogs(driver, session)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		collectBrowserLogs(driver, session)
+	}
+}
+
+func collectBrowserLogs(driver selenium.WebDriver, session *Session) {
+	var entries []models.BrowserLogEntry
+
+	consoleMessages, err := driver.Log(seleniumlog.Browser)
+	if err != nil {
+		log.Printf("Warning: Failed to read browser logs: %v", err)
+	}
+	for _, message := range consoleMessages {
+		entries = append(entries, newBrowserLogEntry(string(seleniumlog.Browser), message.Timestamp, string(message.Level), message.Message))
+	}
+
+	perfMessages, err := driver.Log(seleniumlog.Performance)
+	if err != nil {
+		log.Printf("Warning: Failed to read performance logs: %v", err)
+	}
+	for _, message := range perfMessages {
+		networkEvent, level, ok := parseNetworkEvent(message.Message)
+		if !ok {
+			continue
+		}
+		entries = append(entries, newBrowserLogEntry(string(seleniumlog.Performance), message.Timestamp, string(level), networkEvent))
+	}
+
+	if len(entries) > 0 {
+		session.addBrowserLogs(entries)
+	}
+}
+
+func newBrowserLogEntry(source string, timestamp time.Time, level string, message string) models.BrowserLogEntry {
+	reason, fatal := ClassifyBrowserLog(message)
+	return models.BrowserLogEntry{
+		Time:    timestamp,
+		Source:  source,
+		Level:   level,
+		Message: message,
+		Fatal:   fatal,
+		Reason:  reason,
+	}
+}
+
+// parseNetworkEvent extracts failed requests and closed WebSockets from a
+// DevTools performance log message, with their level; everything else is
+// dropped.
+func parseNetworkEvent(raw string) (string, seleniumlog.Level, bool) {
+	var entry struct {
+		Message struct {
+			Method string `json:"method"`
+			Params struct {
+				ErrorText    string `json:"errorText"`
+				ErrorMessage string `json:"errorMessage"`
+				Canceled     bool   `json:"canceled"`
+				Type         string `json:"type"`
+				Response     struct {
+					URL    string `json:"url"`
+					Status int    `json:"status"`
+				} `json:"response"`
+			} `json:"params"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return "", "", false
+	}
+
+	params := entry.Message.Params
+	switch entry.Message.Method {
+	case "Network.loadingFailed":
+		if params.Canceled {
+			return "", "", false
+		}
+		return fmt.Sprintf("Network.loadingFailed: %s (%s)", params.ErrorText, params.Type), seleniumlog.Severe, true
+	case "Network.webSocketClosed":
+		return "Network.webSocketClosed", seleniumlog.Info, true
+	case "Network.webSocketFrameError":
+		return "Network.webSocketFrameError: " + params.ErrorMessage, seleniumlog.Severe, true
+	case "Network.responseReceived":
+		if params.Response.Status < 500 {
+			return "", "", false
+		}
+		return fmt.Sprintf("Network.responseReceived: HTTP %d %s", params.Response.Status, params.Response.URL), seleniumlog.Severe, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,47 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Browser Log Service", func() {
+	DescribeTable("ClassifyBrowserLog",
+		func(message string, expectedReason string, expectedFatal bool) {
+			reason, fatal := services.ClassifyBrowserLog(message)
+			Expect(fatal).To(Equal(expectedFatal))
+			Expect(reason).To(Equal(expectedReason))
+		},
+		Entry("websocket failure", "WebSocket connection to 'wss://bbb.example.com/html5client/sockjs' failed", "websocket_disconnected", true),
+		Entry("websocket frame error from network log", "Network.webSocketFrameError: invalid frame", "websocket_disconnected", true),
+		Entry("websocket closed from network log", "Network.webSocketClosed", "", false),
+		Entry("websocket closed while sending", "WebSocket is already in CLOSING or CLOSED state.", "", false),
+		Entry("ICE failure", "SFU bridge: ICE connection state changed to failed", "sfu_failure", true),
+		Entry("media permission error", "NotAllowedError: Permission denied", "media_permission_denied", true),
+		Entry("audio join failure", "Failed to join audio: 1002", "audio_join_failed", true),
+		Entry("harmless console message", "Download the React DevTools for a better development experience", "", false),
+	)
+})
+
+var _ = Describe("Session registry", func() {
+	It("should register new sessions", func() {
+		session := services.NewSession(&models.BroadcasterRequest{
+			BBBServerURL:      "https://example.com/bigbluebutton",
+			BBBHealthCheckURL: "https://example.com/health",
+		})
+		Expect(session.ID).NotTo(BeEmpty())
+
+		found, ok := services.GetSession(session.ID)
+		Expect(ok).To(BeTrue())
+		Expect(found).To(BeIdenticalTo(session))
+		Expect(found.Snapshot().ID).To(Equal(session.ID))
+	})
+
+	It("should not find unknown sessions", func() {
+		_, ok := services.GetSession("unknown")
+		Expect(ok).To(BeFalse())
+	})
+})
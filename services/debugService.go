@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"spoutbreeze/models"
+)
+
+const (
+	moonVideoAttempts      = 10
+	moonVideoRetryInterval = 3 * time.Second
+	moonVideoTimeout       = 5 * time.Minute
+)
+
+// errMoonVideoNotReady is returned while Moon is still saving the video.
+var errMoonVideoNotReady = errors.New("Moon video is not available yet")
+
+// moonURL is the root of the Moon hub, where its VNC and devtools endpoints
+// live next to the WebDriver API.
+func moonURL() string {
+	return strings.TrimSuffix(seleniumHubURL(), "/wd/hub")
+}
+
+// moonVideoURL is where Moon's session videos can be downloaded from, such as
+// the public URL of the S3 bucket Moon uploads them to.
+func moonVideoURL() string {
+	if videoURL := os.Getenv("MOON_VIDEO_URL"); videoURL != "" {
+		return strings.TrimRight(videoURL, "/")
+	}
+	return moonURL() + "/video"
+}
+
+// debugMoonOptions turns on Moon's VNC and video for the bot when the request
+// asks for them. The video is registered with the session's recordings and
+// fetched once the browser is gone.
+func debugMoonOptions(session *Session) map[string]interface{} {
+	options := map[string]interface{}{
+		"enableVNC":   session.Request.EnableVNC,
+		"enableVideo": session.Request.EnableVideo,
+	}
+	if session.Request.EnableVideo {
+		video := session.addRecording(models.RecordingFormatMP4, models.RecordingSourceMoonVideo)
+		options["videoName"] = video.Name
+	}
+	return options
+}
+
+// discardMoonVideo marks the video of a browser that did not start as failed.
+func discardMoonVideo(session *Session, moonOptions map[string]interface{}) {
+	if name, ok := moonOptions["videoName"].(string); ok {
+		session.setRecordingState(name, models.RecordingStateFailed, nil)
+	}
+}
+
+// debugEndpoints returns where the live bot can be reached, nil while no
+// browser is running or when the request did not ask for debugging. Called
+// with the session lock held.
+func (s *Session) debugEndpoints() *models.DebugEndpoints {
+	if s.driver == nil || !(s.Request.EnableVNC || s.Request.EnableVideo) {
+		return nil
+	}
+	moonSessionID := s.driver.SessionID()
+	wsURL := "ws" + strings.TrimPrefix(moonURL(), "http")
+	endpoints := &models.DebugEndpoints{
+		MoonSessionID: moonSessionID,
+		DevtoolsURL:   wsURL + "/devtools/" + moonSessionID,
+	}
+	if s.Request.EnableVNC {
+		endpoints.VNCURL = wsURL + "/vnc/" + moonSessionID
+	}
+	return endpoints
+}
+
+// fetchMoonVideos downloads the Moon videos of browsers that are gone into
+// the session's recordings directory, in the background as Moon needs a
+// moment to save them.
+func fetchMoonVideos(session *Session) {
+	endedAt := time.Now()
+	for _, recording := range session.Recordings() {
+		if recording.Source != models.RecordingSourceMoonVideo || recording.State != models.RecordingStateRecording || recording.EndedAt != nil {
+			continue
+		}
+		fetchMoonVideo(session, recording.Name, endedAt)
+	}
+}
+
+// fetchMoonVideo downloads one Moon video in the background.
+func fetchMoonVideo(session *Session, name string, endedAt time.Time) {
+	session.setRecordingState(name, models.RecordingStateRecording, &endedAt)
+
+	go func() {
+		client := &http.Client{Timeout: moonVideoTimeout}
+		path := filepath.Join(recordingsDir(), session.ID, name)
+		var err error
+		for attempt := 0; attempt < moonVideoAttempts; attempt++ {
+			time.Sleep(moonVideoRetryInterval)
+			err = FetchMoonVideo(client, name, path)
+			if !errors.Is(err, errMoonVideoNotReady) {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to fetch Moon video %s: %v", name, err)
+			session.setRecordingState(name, models.RecordingStateFailed, nil)
+			session.AddEvent("moon_video_failed", err.Error())
+			return
+		}
+		session.setRecordingState(name, models.RecordingStateCompleted, nil)
+		session.AddEvent("moon_video_saved", name)
+	}()
+}
+
+// FetchMoonVideo downloads a Moon session video to path. The file only
+// appears once it is complete.
+func FetchMoonVideo(client *http.Client, name string, path string) error {
+	resp, err := client.Get(moonVideoURL() + "/" + name)
+	if err != nil {
+		return fmt.Errorf("failed to download Moon video: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errMoonVideoNotReady
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Moon video download answered %s", resp.Status)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".moon-video-*")
+	if err != nil {
+		return fmt.Errorf("failed to store Moon video: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store Moon video: %w", err)
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,75 @@
+package services_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/tebeka/selenium"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// fakeMoonBrowser is a running bot browser on Moon.
+type fakeMoonBrowser struct {
+	selenium.WebDriver
+}
+
+func (b *fakeMoonBrowser) SessionID() string {
+	return "moon-session"
+}
+
+var _ = Describe("Debug Service", func() {
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/videos/moon-20260101T100000Z-1.mp4" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte("video"))
+		}))
+		DeferCleanup(server.Close)
+		GinkgoT().Setenv("MOON_VIDEO_URL", server.URL+"/videos/")
+	})
+
+	It("should store the Moon video of a session", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "session", "moon-20260101T100000Z-1.mp4")
+
+		Expect(services.FetchMoonVideo(server.Client(), "moon-20260101T100000Z-1.mp4", path)).To(Succeed())
+		Expect(os.ReadFile(path)).To(Equal([]byte("video")))
+	})
+
+	It("should not store anything while Moon has no video yet", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "moon-20260101T100000Z-2.mp4")
+
+		Expect(services.FetchMoonVideo(server.Client(), "moon-20260101T100000Z-2.mp4", path)).NotTo(Succeed())
+		Expect(path).NotTo(BeAnExistingFile())
+	})
+
+	It("should not report debug endpoints without a running browser", func() {
+		session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf", EnableVNC: true})
+
+		Expect(session.Snapshot().Debug).To(BeNil())
+	})
+
+	It("should only report debug endpoints when debugging was asked for", func() {
+		session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf"})
+		session.SetDriver(&fakeMoonBrowser{})
+		Expect(session.Snapshot().Debug).To(BeNil())
+
+		session = services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf", EnableVideo: true})
+		session.SetDriver(&fakeMoonBrowser{})
+		debug := session.Snapshot().Debug
+		Expect(debug).NotTo(BeNil())
+		Expect(debug.MoonSessionID).To(Equal("moon-session"))
+		Expect(debug.DevtoolsURL).To(HaveSuffix("/devtools/moon-session"))
+		Expect(debug.VNCURL).To(BeEmpty())
+	})
+})
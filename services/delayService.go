@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+	"spoutbreeze/relay"
+)
+
+// ErrNoDelay rejects dumping a session that was started without delay_seconds.
+var ErrNoDelay = errors.New("session has no delay buffer")
+
+const defaultDumpSlateText = "We'll be right back"
+
+// validateDelay checks that a delayed request can be held back as a whole.
+// Only the RTMP relay buffers the output, so every destination has to go
+// through it.
+func validateDelay(request *models.BroadcasterRequest, destinations []models.Destination) error {
+	if request.DelaySeconds == 0 {
+		return nil
+	}
+	if rtmpRelay == nil {
+		return fmt.Errorf("%w: delay_seconds needs the RTMP relay, set RTMP_RELAY_ADDR", ErrInvalidRequest)
+	}
+	if len(request.WHIPDestinations) > 0 {
+		return fmt.Errorf("%w: WHIP destinations cannot be delayed", ErrInvalidRequest)
+	}
+	for _, destination := range destinations {
+		protocol := DestinationProtocol(destination)
+		if protocol != models.ProtocolRTMP && protocol != models.ProtocolRTMPS {
+			return fmt.Errorf("%w: destination %q cannot be delayed, only RTMP and RTMPS destinations can", ErrInvalidRequest, destination.Label)
+		}
+	}
+	return nil
+}
+
+// requestDelay is the delay the relay holds the session's output for.
+func requestDelay(request *models.BroadcasterRequest) time.Duration {
+	return time.Duration(request.DelaySeconds) * time.Second
+}
+
+// dumpSlate is the slate shown after a dump.
+func dumpSlate(request *models.BroadcasterRequest) models.Slate {
+	slate := models.Slate{Text: defaultDumpSlateText}
+	if request.DumpSlate != nil {
+		slate = *request.DumpSlate
+	}
+	return slate
+}
+
+// applyDumpSlate reinstalls the slate of a dumped session in case the client
+// reloaded the page on its own.
+func applyDumpSlate(driver selenium.WebDriver, session *Session) {
+	if !session.dumped() {
+		return
+	}
+	err := showSlate(driver, dumpSlate(session.Request), false)
+	if err != nil {
+		log.Printf("Warning: Failed to reinstall the slate: %v", err)
+	}
+}
+
+// ApplyDelayAction dumps or resumes the delay buffer of a session and of the
+// bots started with it for other orientations, which carry the same
+// program. A dump puts the slate up before the relay drops the buffer, so
+// nothing that was on screen before it goes out undelayed. A resume takes
+// the slate down once the relay is delaying again.
+func ApplyDelayAction(sessionID string, action string) error {
+	session, ok := GetSession(sessionID)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if session.Request.DelaySeconds == 0 || rtmpRelay == nil {
+		return ErrNoDelay
+	}
+
+	group := []*Session{session}
+	for _, companionID := range session.Companions() {
+		if companion, ok := GetSession(companionID); ok {
+			group = append(group, companion)
+		}
+	}
+	for _, member := range group {
+		if member.Driver() == nil || member.State() != models.SessionStateLive {
+			return ErrSessionNotLive
+		}
+	}
+
+	for _, member := range group {
+		var err error
+		switch action {
+		case models.DelayActionDump:
+			err = dumpDelay(member)
+		case models.DelayActionResume:
+			err = resumeDelay(member)
+		default:
+			err = fmt.Errorf("%w: unknown delay action %q", ErrInvalidRequest, action)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dumpDelay(session *Session) error {
+	err := showSlate(session.Driver(), dumpSlate(session.Request), false)
+	if err != nil {
+		return fmt.Errorf("failed to show the slate, the buffer was not dumped: %w", err)
+	}
+	err = rtmpRelay.Dump(session.relayKey)
+	if errors.Is(err, relay.ErrUnknownStream) || errors.Is(err, relay.ErrNoDelay) {
+		return ErrNoDelay
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	session.setDumpedAt(&now)
+	session.AddEvent("delay_dumped", fmt.Sprintf("dropped the %ds delay buffer, slate on air", session.Request.DelaySeconds))
+	return nil
+}
+
+func resumeDelay(session *Session) error {
+	err := rtmpRelay.Resume(session.relayKey)
+	if errors.Is(err, relay.ErrUnknownStream) || errors.Is(err, relay.ErrNoDelay) {
+		return ErrNoDelay
+	}
+	if err != nil {
+		return err
+	}
+	session.setDumpedAt(nil)
+	// A fallback put up meanwhile stays on air
+	if cover := session.coverSlate(); cover != nil {
+		err = showSlate(session.Driver(), *cover, false)
+	} else {
+		err = hideSlate(session.Driver(), false)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to take the slate down: %w", err)
+	}
+	session.AddEvent("delay_resumed", fmt.Sprintf("slate off, the destinations get the meeting again in %ds", session.Request.DelaySeconds))
+	return nil
+}
+
+func (s *Session) setDumpedAt(at *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dumpedAt = at
+}
+
+func (s *Session) dumped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dumpedAt != nil
+}
+
+// delayStatus is called with the lock held.
+func (s *Session) delayStatus() *models.DelayStatus {
+	if s.Request == nil || s.Request.DelaySeconds == 0 {
+		return nil
+	}
+	return &models.DelayStatus{Seconds: s.Request.DelaySeconds, Dumped: s.dumpedAt != nil, DumpedAt: s.dumpedAt}
+}
@@ -0,0 +1,32 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Delay Service", func() {
+	Describe("ApplyDelayAction", func() {
+		It("should return ErrSessionNotFound for unknown sessions", func() {
+			err := services.ApplyDelayAction("unknown", models.DelayActionDump)
+			Expect(err).To(MatchError(services.ErrSessionNotFound))
+		})
+
+		It("should return ErrNoDelay for sessions started without a delay", func() {
+			session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf"})
+
+			err := services.ApplyDelayAction(session.ID, models.DelayActionDump)
+			Expect(err).To(MatchError(services.ErrNoDelay))
+			Expect(session.Snapshot().Delay).To(BeNil())
+		})
+	})
+
+	It("should report the delay of a session", func() {
+		session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf", DelaySeconds: 20})
+
+		Expect(session.Snapshot().Delay).To(Equal(&models.DelayStatus{Seconds: 20}))
+	})
+})
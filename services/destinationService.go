@@ -0,0 +1,284 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"spoutbreeze/initializers"
+	"spoutbreeze/models"
+	"spoutbreeze/repositories"
+)
+
+const defaultDestinationLabel = "default"
+
+// requestDestinations returns the destinations of a request, falling back to
+// the single rtmp_url and stream_key pair. A request that only publishes over
+// WHIP has none.
+func requestDestinations(request *models.BroadcasterRequest) []models.Destination {
+	if len(request.Destinations) > 0 {
+		return request.Destinations
+	}
+	if request.RTMPURL == "" && request.Platform == "" && len(request.WHIPDestinations) > 0 {
+		return nil
+	}
+	return []models.Destination{{URL: request.RTMPURL, Key: request.StreamKey, Label: defaultDestinationLabel, Platform: request.Platform, SRT: request.SRT, Orientation: request.Orientation}}
+}
+
+// prepareDestinations resolves the destinations of a request against their
+// platform presets and validates them, and the quality profile, before a
+// session is started.
+func prepareDestinations(request *models.BroadcasterRequest) error {
+	destinations, err := resolveDestinations(requestDestinations(request))
+	if err != nil {
+		return err
+	}
+	err = validateDestinations(destinations, request.WHIPDestinations)
+	if err != nil {
+		return err
+	}
+	err = validateQuality(requestQuality(request), destinations)
+	if err != nil {
+		return err
+	}
+	err = validateDelay(request, destinations)
+	if err != nil {
+		return err
+	}
+	request.Destinations = destinations
+	return nil
+}
+
+// validateDestinations checks the destinations of both kinds together, as
+// they share the label namespace of the session's destination statuses.
+func validateDestinations(destinations []models.Destination, whipDestinations []models.WHIPDestination) error {
+	labels := map[string]bool{}
+	for _, destination := range whipDestinations {
+		if labels[destination.Label] {
+			return fmt.Errorf("%w: duplicate destination label %q", ErrInvalidRequest, destination.Label)
+		}
+		labels[destination.Label] = true
+	}
+	for _, destination := range destinations {
+		if labels[destination.Label] {
+			return fmt.Errorf("%w: duplicate destination label %q", ErrInvalidRequest, destination.Label)
+		}
+		labels[destination.Label] = true
+		if destination.SRT != nil && DestinationProtocol(destination) != models.ProtocolSRT {
+			return fmt.Errorf("%w: destination %q has srt options but is not an srt:// URL", ErrInvalidRequest, destination.Label)
+		}
+	}
+	return nil
+}
+
+// DestinationProtocol returns the output protocol of a destination from its
+// URL scheme.
+func DestinationProtocol(destination models.Destination) string {
+	scheme, _, _ := strings.Cut(destination.URL, "://")
+	return strings.ToLower(scheme)
+}
+
+// DestinationURL joins the ingest URL and stream key of a destination. SRT
+// has no path for the key, it is sent as the stream ID together with the SRT
+// options as query parameters. Icecast mounts take the key as the source
+// password.
+func DestinationURL(destination models.Destination) string {
+	if DestinationProtocol(destination) == models.ProtocolSRT {
+		return srtURL(destination)
+	}
+	if isIcecast(destination) {
+		return icecastURL(destination)
+	}
+	if destination.Key == "" {
+		return destination.URL
+	}
+	return strings.TrimRight(destination.URL, "/") + "/" + destination.Key
+}
+
+// srtURL builds an ffmpeg SRT output URL. ffmpeg expects the latency in
+// microseconds.
+func srtURL(destination models.Destination) string {
+	options := models.SRTOptions{}
+	if destination.SRT != nil {
+		options = *destination.SRT
+	}
+	if options.StreamID == "" {
+		options.StreamID = destination.Key
+	}
+
+	base, rawQuery, _ := strings.Cut(destination.URL, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		query = url.Values{}
+	}
+	if options.StreamID != "" {
+		query.Set("streamid", options.StreamID)
+	}
+	if options.Passphrase != "" {
+		query.Set("passphrase", options.Passphrase)
+	}
+	if options.PBKeyLen != 0 {
+		query.Set("pbkeylen", strconv.Itoa(options.PBKeyLen))
+	}
+	if options.LatencyMs != 0 {
+		query.Set("latency", strconv.Itoa(options.LatencyMs*1000))
+	}
+	if len(query) == 0 {
+		return base
+	}
+	return base + "?" + query.Encode()
+}
+
+// teeFormat is the ffmpeg container used for a destination: FLV over RTMP
+// and RTMPS, MPEG-TS over SRT and a bare audio stream for Icecast mounts.
+func teeFormat(destination models.Destination, audioCodec string) string {
+	if DestinationProtocol(destination) == models.ProtocolSRT {
+		return "mpegts"
+	}
+	if isIcecast(destination) {
+		return icecastFormats[audioCodec].Format
+	}
+	return "flv"
+}
+
+// teeEscaper escapes the characters the tee muxer splits its outputs and
+// their options on, and its own escape and quote characters, in the URLs
+// and keys the request brings.
+var teeEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `|`, `\|`, `[`, `\[`, `]`, `\]`, `:`, `\:`)
+
+// teeOutput is the tee muxer target of a destination. Icecast mounts are also
+// told the content type of the stream.
+func teeOutput(destination models.Destination, audioCodec string) string {
+	options := "f=" + teeFormat(destination, audioCodec) + ":onfail=ignore"
+	if isIcecast(destination) {
+		options += ":content_type=" + icecastFormats[audioCodec].ContentType
+	}
+	return "[" + options + "]" + teeEscaper.Replace(DestinationURL(destination))
+}
+
+// destinationMoonEnv describes the destinations to the capture container.
+// RTMP_BASE_URL and STREAM_KEY carry the first destination for images that
+// only push to one, with the key also in Twitch_KEY, which existing images
+// read. The RTMP_ prefix is kept for every protocol for the same reason.
+// FFMPEG_TEE_OUTPUTS is a ready-made ffmpeg tee muxer target where each
+// output uses onfail=ignore, so a failing destination does not stop the
+// others. extraOutputs, such as a recording, are added to it as they are.
+func destinationMoonEnv(sessionID string, destinations []models.Destination, audioCodec string, extraOutputs ...string) []string {
+	var first models.Destination
+	if len(destinations) > 0 {
+		first = destinations[0]
+	}
+	env := []string{
+		"SESSION_ID=" + sessionID,
+		"RTMP_BASE_URL=" + first.URL,
+		"STREAM_KEY=" + first.Key,
+		"Twitch_KEY=" + first.Key,
+		"STREAM_PLATFORM=" + first.Platform,
+		"STREAM_PROTOCOL=" + DestinationProtocol(first),
+		"RTMP_DESTINATIONS_COUNT=" + strconv.Itoa(len(destinations)),
+	}
+
+	var teeOutputs []string
+	for i, destination := range destinations {
+		prefix := fmt.Sprintf("RTMP_DESTINATION_%d_", i)
+		env = append(env,
+			prefix+"URL="+destination.URL,
+			prefix+"KEY="+destination.Key,
+			prefix+"LABEL="+destination.Label,
+			prefix+"PLATFORM="+destination.Platform,
+			prefix+"PROTOCOL="+DestinationProtocol(destination),
+			prefix+"OUTPUT_URL="+DestinationURL(destination),
+		)
+		if srt := destination.SRT; srt != nil {
+			env = append(env,
+				prefix+"SRT_PASSPHRASE="+srt.Passphrase,
+				prefix+"SRT_LATENCY_MS="+strconv.Itoa(srt.LatencyMs),
+				prefix+"SRT_STREAM_ID="+srt.StreamID,
+				prefix+"SRT_PBKEYLEN="+strconv.Itoa(srt.PBKeyLen),
+			)
+		}
+		teeOutputs = append(teeOutputs, teeOutput(destination, audioCodec))
+	}
+	teeOutputs = append(teeOutputs, extraOutputs...)
+	return append(env, "FFMPEG_TEE_OUTPUTS="+strings.Join(teeOutputs, "|"))
+}
+
+func newDestinationStatuses(destinations []models.Destination, whipDestinations []models.WHIPDestination) []models.DestinationStatus {
+	statuses := make([]models.DestinationStatus, 0, len(destinations)+len(whipDestinations))
+	for _, destination := range destinations {
+		statuses = append(statuses, models.DestinationStatus{
+			Label:       destination.Label,
+			Platform:    destination.Platform,
+			Protocol:    DestinationProtocol(destination),
+			Orientation: orientationOf(destination.Orientation),
+			URL:         destination.URL,
+			State:       models.DestinationStatePending,
+			UpdatedAt:   time.Now(),
+		})
+	}
+	for _, destination := range whipDestinations {
+		statuses = append(statuses, models.DestinationStatus{
+			Label:       destination.Label,
+			Protocol:    models.ProtocolWHIP,
+			Orientation: orientationOf(destination.Orientation),
+			URL:         destination.EndpointURL,
+			State:       models.DestinationStatePending,
+			UpdatedAt:   time.Now(),
+		})
+	}
+	return statuses
+}
+
+// refreshDestinationStatuses pulls the status the capture container wrote to
+// Redis for each destination. Without Redis the statuses stay pending.
+func refreshDestinationStatuses(session *Session) {
+	if initializers.RedisClient == nil {
+		return
+	}
+	reported, err := repositories.GetDestinationStatuses(session.ID)
+	if err != nil {
+		log.Printf("Warning: Failed to read destination statuses: %v", err)
+		return
+	}
+
+	for label, raw := range reported {
+		var status struct {
+			State string `json:"state"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(raw), &status); err != nil {
+			log.Printf("Warning: Invalid status for destination %s: %v", label, err)
+			continue
+		}
+		session.setDestinationStatus(label, status.State, status.Error)
+	}
+}
+
+// setDestinationStatus records a destination status change as a session event.
+func (s *Session) setDestinationStatus(label string, state string, message string) {
+	s.mu.Lock()
+	changed := false
+	for i := range s.destinations {
+		destination := &s.destinations[i]
+		if destination.Label != label || (destination.State == state && destination.Error == message) {
+			continue
+		}
+		destination.State = state
+		destination.Error = message
+		destination.UpdatedAt = time.Now()
+		changed = true
+	}
+	s.mu.Unlock()
+
+	if changed {
+		event := label + " " + state
+		if message != "" {
+			event += ": " + message
+		}
+		s.AddEvent("destination_status", event)
+	}
+}
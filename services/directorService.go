@@ -0,0 +1,168 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+var defaultDirectorRules = []models.DirectorRule{
+	{On: models.DirectorEventScreenshareStarted, Action: models.LayoutActionPresentationFullscreen},
+	{On: models.DirectorEventScreenshareStopped, Action: models.LayoutActionSmartLayout},
+}
+
+// DirectorState is what the auto-director observes in the BBB client.
+type DirectorState struct {
+	Screenshare  bool   `json:"screenshare"`
+	Presenter    string `json:"presenter"`
+	Presentation string `json:"presentation"`
+	Webcams      int    `json:"webcams"`
+}
+
+// presenterSelectors are the elements of the presentation and webcam areas
+// that carry the presenter's name, BBB 2.7 first. The user list cannot be
+// used, the join steps close its panel and the clean feed hides it.
+var presenterSelectors = []string{
+	`[data-test="whiteboardCursorIndicator"]`,
+	`[data-test="presentationContainer"] [class*="presenterName"]`,
+	`[data-test="webcamItem"][class*="presenter"] [data-test="webcamUsername"]`,
+}
+
+// directorStateScript reads the meeting state from the BBB client DOM, with
+// the text of the elements found for the presenter selectors in
+// arguments[0], by selector. The current presentation is identified by the
+// presentation ID in the slide URL.
+const directorStateScript = `
+var presenters = {};
+arguments[0].forEach(function (selector) {
+	var element = document.querySelector(selector);
+	if (element) {
+		presenters[selector] = element.innerText || element.getAttribute("aria-label") || "";
+	}
+});
+var presentation = "";
+var slide = document.querySelector('[data-test="slideImage"], svg image');
+if (slide) {
+	var match = (slide.getAttribute("href") || slide.getAttribute("xlink:href") || slide.getAttribute("src") || "").match(/\/presentation\/[^\/]+\/[^\/]+\/([^\/]+)\//);
+	presentation = match ? match[1] : "";
+}
+return JSON.stringify({
+	screenshare: !!document.querySelector('[data-test="screenShareVideo"], video#screenshareVideo'),
+	presenters: presenters,
+	presentation: presentation,
+	webcams: document.querySelectorAll('[data-test="webcamItem"], [data-test="webcamItemTalkUser"]').length
+});
+`
+
+// ReadDirectorState observes the meeting state in the BBB client.
+func ReadDirectorState(driver selenium.WebDriver) (DirectorState, error) {
+	var observed struct {
+		DirectorState
+		Presenters map[string]string `json:"presenters"`
+	}
+	result, err := driver.ExecuteScript(directorStateScript, []interface{}{presenterSelectors})
+	if err != nil {
+		return DirectorState{}, fmt.Errorf("failed to read meeting state: %w", err)
+	}
+	raw, _ := result.(string)
+	if err := json.Unmarshal([]byte(raw), &observed); err != nil {
+		return DirectorState{}, fmt.Errorf("failed to parse meeting state: %w", err)
+	}
+	for _, selector := range presenterSelectors {
+		name := strings.TrimSpace(strings.SplitN(observed.Presenters[selector], "\n", 2)[0])
+		if name != "" {
+			observed.Presenter = name
+			break
+		}
+	}
+	return observed.DirectorState, nil
+}
+
+// DirectorEvents lists the meeting events between two observations.
+func DirectorEvents(previous DirectorState, current DirectorState) []string {
+	var events []string
+	if !previous.Screenshare && current.Screenshare {
+		events = append(events, models.DirectorEventScreenshareStarted)
+	}
+	if previous.Screenshare && !current.Screenshare {
+		events = append(events, models.DirectorEventScreenshareStopped)
+	}
+	if current.Presenter != "" && previous.Presenter != current.Presenter {
+		events = append(events, models.DirectorEventPresenterChanged)
+	}
+	if current.Presentation != "" && previous.Presentation != current.Presentation {
+		events = append(events, models.DirectorEventPresentationUploaded)
+	}
+	if previous.Webcams == 0 && current.Webcams > 0 {
+		events = append(events, models.DirectorEventWebcamsOn)
+	}
+	if previous.Webcams > 0 && current.Webcams == 0 {
+		events = append(events, models.DirectorEventWebcamsOff)
+	}
+	return events
+}
+
+// autoDirector applies layout rules as meeting events are observed. The
+// first observation after (re)joining only seeds the state.
+type autoDirector struct {
+	rules    []models.DirectorRule
+	previous *DirectorState
+}
+
+func newAutoDirector(config *models.AutoDirector) *autoDirector {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = defaultDirectorRules
+	}
+	return &autoDirector{rules: rules}
+}
+
+func (d *autoDirector) reset() {
+	d.previous = nil
+}
+
+func (d *autoDirector) tick(driver selenium.WebDriver, session *Session) {
+	current, err := ReadDirectorState(driver)
+	if err != nil {
+		log.Printf("Warning: Auto-director %v", err)
+		return
+	}
+
+	previous := d.previous
+	if previous != nil && current.Presenter == "" {
+		// The presenter's name is only on the page while their cursor is
+		// on the whiteboard or their webcam is on, keep the last one seen
+		current.Presenter = previous.Presenter
+	}
+	d.previous = &current
+	if previous == nil {
+		return
+	}
+
+	for _, event := range DirectorEvents(*previous, current) {
+		session.AddEvent("director_event", event)
+		for _, rule := range d.rules {
+			if rule.On != event {
+				continue
+			}
+			err := checkLayoutAction(session.Request, rule.Action)
+			if err == nil {
+				err = runLayoutAction(driver, rule.Action)
+			}
+			if err != nil {
+				log.Printf("Warning: Auto-director failed to apply %s: %v", rule.Action, err)
+				session.AddEvent("director_error", event+" -> "+rule.Action+": "+err.Error())
+				continue
+			}
+			session.setLayoutAction(rule.Action)
+			session.AddEvent("layout_changed", "auto-director: "+event+" -> "+rule.Action)
+		}
+	}
+}
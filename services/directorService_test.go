@@ -0,0 +1,105 @@
+package services_test
+
+import (
+	"encoding/json"
+
+	"github.com/tebeka/selenium"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// fakeMeetingPage is a BBB client whose elements are given by selector. It
+// answers the director state script from them.
+type fakeMeetingPage struct {
+	selenium.WebDriver
+	elements map[string]string
+}
+
+func (p *fakeMeetingPage) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	presenters := map[string]string{}
+	for _, selector := range args[0].([]string) {
+		if text, ok := p.elements[selector]; ok {
+			presenters[selector] = text
+		}
+	}
+	raw, err := json.Marshal(map[string]interface{}{"presenters": presenters})
+	return string(raw), err
+}
+
+var _ = Describe("Director Service", func() {
+	Describe("ReadDirectorState", func() {
+		It("should find the presenter with the users panel closed", func() {
+			page := &fakeMeetingPage{elements: map[string]string{
+				`[data-test="whiteboardCursorIndicator"]`: "Alice\n",
+			}}
+
+			previous, err := services.ReadDirectorState(page)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(previous.Presenter).To(Equal("Alice"))
+
+			page.elements = map[string]string{
+				`[data-test="webcamItem"][class*="presenter"] [data-test="webcamUsername"]`: "Bob",
+			}
+			current, err := services.ReadDirectorState(page)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services.DirectorEvents(previous, current)).To(Equal([]string{models.DirectorEventPresenterChanged}))
+		})
+	})
+
+	It("should keep the presenter while their name is briefly off the page", func() {
+		session := services.NewSession(&models.BroadcasterRequest{})
+		director := services.NewAutoDirector(&models.AutoDirector{Enabled: true})
+		cursor := `[data-test="whiteboardCursorIndicator"]`
+		page := &fakeMeetingPage{}
+
+		for _, presenter := range []string{"Alice", "", "Alice", "", "Bob"} {
+			page.elements = map[string]string{}
+			if presenter != "" {
+				page.elements[cursor] = presenter
+			}
+			director.Tick(page, session)
+		}
+
+		var events []string
+		for _, event := range session.Snapshot().Events {
+			if event.Type == "director_event" {
+				events = append(events, event.Message)
+			}
+		}
+		Expect(events).To(Equal([]string{models.DirectorEventPresenterChanged}))
+	})
+
+	DescribeTable("DirectorEvents",
+		func(previous services.DirectorState, current services.DirectorState, expected []string) {
+			Expect(services.DirectorEvents(previous, current)).To(Equal(expected))
+		},
+		Entry("nothing changed",
+			services.DirectorState{Presenter: "Alice", Presentation: "pres-1", Webcams: 2},
+			services.DirectorState{Presenter: "Alice", Presentation: "pres-1", Webcams: 3},
+			nil),
+		Entry("screenshare started",
+			services.DirectorState{},
+			services.DirectorState{Screenshare: true},
+			[]string{models.DirectorEventScreenshareStarted}),
+		Entry("screenshare stopped",
+			services.DirectorState{Screenshare: true},
+			services.DirectorState{},
+			[]string{models.DirectorEventScreenshareStopped}),
+		Entry("presenter changed and new presentation",
+			services.DirectorState{Presenter: "Alice", Presentation: "pres-1"},
+			services.DirectorState{Presenter: "Bob", Presentation: "pres-2"},
+			[]string{models.DirectorEventPresenterChanged, models.DirectorEventPresentationUploaded}),
+		Entry("webcams toggled on",
+			services.DirectorState{},
+			services.DirectorState{Webcams: 1},
+			[]string{models.DirectorEventWebcamsOn}),
+		Entry("webcams toggled off",
+			services.DirectorState{Webcams: 4},
+			services.DirectorState{},
+			[]string{models.DirectorEventWebcamsOff}),
+	)
+})
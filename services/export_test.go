@@ -0,0 +1,146 @@
+package services
+
+import (
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/relay"
+)
+
+// ShortenGreenlightTimings speeds the Greenlight join up for a test and
+// returns a function that restores the timings.
+func ShortenGreenlightTimings(poll time.Duration, timeout time.Duration) func() {
+	savedPoll, savedTimeout := greenlightPollInterval, greenlightJoinTimeout
+	greenlightPollInterval = poll
+	greenlightJoinTimeout = timeout
+	return func() {
+		greenlightPollInterval, greenlightJoinTimeout = savedPoll, savedTimeout
+	}
+}
+
+// MonitorMeeting runs the meeting watch against a fake browser.
+var MonitorMeeting = monitorMeeting
+
+// ShortenMonitorTimings speeds the meeting watch up for a test and returns
+// a function that restores the timings.
+func ShortenMonitorTimings(poll time.Duration, recoveryDeadline time.Duration) func() {
+	saved := []time.Duration{meetingScreenPollInterval, healthCheckInterval, fallbackSceneHold, clientRecoveryDeadline, pageLoadWait}
+	meetingScreenPollInterval = poll
+	healthCheckInterval = poll
+	fallbackSceneHold = 0
+	clientRecoveryDeadline = recoveryDeadline
+	pageLoadWait = 0
+	return func() {
+		meetingScreenPollInterval, healthCheckInterval, fallbackSceneHold, clientRecoveryDeadline, pageLoadWait = saved[0], saved[1], saved[2], saved[3], saved[4]
+	}
+}
+
+// StartWHIP and CheckWHIP publish to WHIP destinations from a fake browser.
+var (
+	StartWHIP = startWHIP
+	CheckWHIP = checkWHIP
+)
+
+// ShortenWHIPBackoff speeds the WHIP retries up for a test and returns a
+// function that restores the backoff.
+func ShortenWHIPBackoff(backoff time.Duration) func() {
+	savedMin, savedMax := whipRetryMinBackoff, whipRetryMaxBackoff
+	whipRetryMinBackoff = backoff
+	whipRetryMaxBackoff = 4 * backoff
+	return func() {
+		whipRetryMinBackoff, whipRetryMaxBackoff = savedMin, savedMax
+	}
+}
+
+// DestinationMoonEnv describes destinations to the capture container.
+var DestinationMoonEnv = destinationMoonEnv
+
+// PrepareDestinations resolves and validates the destinations of a request.
+var PrepareDestinations = prepareDestinations
+
+// NewSessionGroup registers the sessions of a request without starting their
+// bots.
+var NewSessionGroup = newSessionGroup
+
+// SetDriver hands a fake browser to a session.
+func (s *Session) SetDriver(driver selenium.WebDriver) {
+	s.setDriver(driver)
+}
+
+// TestBroadcasterRequest turns a test request into the broadcast it stands for.
+var TestBroadcasterRequest = testBroadcasterRequest
+
+// NewAutoDirector and Tick run the auto-director against a fake browser.
+var NewAutoDirector = newAutoDirector
+
+func (d *autoDirector) Tick(driver selenium.WebDriver, session *Session) {
+	d.tick(driver, session)
+}
+
+// The slates against a fake browser.
+var (
+	WaitForMeetingStart = waitForMeetingStart
+	NavigateUnderSlate  = navigateUnderSlate
+	RevealMeeting       = revealMeeting
+	ShowEndedSlate      = showEndedSlate
+	EndedSlateHold      = endedSlateHold
+)
+
+// ShortenSlateTimings speeds the slates up for a test and returns a function
+// that restores the timings.
+func ShortenSlateTimings(poll time.Duration, timeout time.Duration) func() {
+	saved := []time.Duration{meetingStartPollInterval, meetingStartTimeout, navigationPollInterval, navigationTimeout, slateFade}
+	meetingStartPollInterval = poll
+	meetingStartTimeout = timeout
+	navigationPollInterval = poll
+	navigationTimeout = timeout
+	slateFade = 0
+	return func() {
+		meetingStartPollInterval, meetingStartTimeout, navigationPollInterval, navigationTimeout, slateFade = saved[0], saved[1], saved[2], saved[3], saved[4]
+	}
+}
+
+// UseRelay runs the session services against a relay for a test and returns
+// a function that turns it off again.
+func UseRelay(r *relay.Relay) func() {
+	saved := rtmpRelay
+	rtmpRelay = r
+	return func() {
+		rtmpRelay = saved
+	}
+}
+
+// RelayKey is the stream key a fake capture container publishes under.
+func (s *Session) RelayKey() string {
+	return s.relayKey
+}
+
+// CaptureDestinations and StartRecording set up what a fake browser would
+// capture.
+var (
+	CaptureDestinations = captureDestinations
+	StartRecording      = startRecording
+)
+
+// RestartBotBrowser runs a restart against fake browsers.
+var RestartBotBrowser = restartBotBrowser
+
+// FakeReplacementBrowser has restarts start their replacement browser with
+// start and returns a function that restores the real one.
+func FakeReplacementBrowser(start func(session *Session, relayKey string) (selenium.WebDriver, error)) func() {
+	saved := startReplacementBrowser
+	startReplacementBrowser = start
+	return func() {
+		startReplacementBrowser = saved
+	}
+}
+
+// ShortenRestartSwitch speeds a restart's switch up for a test and returns a
+// function that restores the timeout.
+func ShortenRestartSwitch(timeout time.Duration) func() {
+	saved := restartSwitchTimeout
+	restartSwitchTimeout = timeout
+	return func() {
+		restartSwitchTimeout = saved
+	}
+}
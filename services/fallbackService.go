@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+// fallbackTimeout bounds how long a broadcast stays on its fallback before
+// the session fails.
+const fallbackTimeout = 30 * time.Minute
+
+// UpdateFallback puts the broadcast's fallback on air while the meeting is
+// unreachable, cause telling why, and takes it down once cause is empty
+// again. The fallback is the cover of rejoins meanwhile, see coverJoin, and
+// stays under the slate of a dump. It is reinstalled on every call in case
+// the client reloaded the page.
+func UpdateFallback(driver selenium.WebDriver, session *Session, cause string) error {
+	fallback := session.Request.Fallback
+	if fallback == nil {
+		return nil
+	}
+	since := session.fallbackStartedAt()
+
+	switch {
+	case cause != "" && since == nil:
+		now := time.Now()
+		session.setFallbackStartedAt(&now)
+		session.setCoverSlate(fallback)
+		session.AddEvent("fallback_on", cause+", switched to the fallback")
+		if !session.dumped() {
+			err := showSlate(driver, *fallback, true)
+			if err != nil {
+				log.Printf("Warning: Failed to show the fallback: %v", err)
+			}
+		}
+
+	case cause != "":
+		if time.Since(*since) > fallbackTimeout {
+			return &SessionError{Reason: models.ReasonConnectionLost, Err: fmt.Errorf("meeting was unreachable for %s", fallbackTimeout)}
+		}
+		if !session.dumped() {
+			err := showSlate(driver, *fallback, false)
+			if err != nil {
+				log.Printf("Warning: Failed to reinstall the fallback: %v", err)
+			}
+		}
+
+	case since != nil:
+		session.setFallbackStartedAt(nil)
+		session.setCoverSlate(nil)
+		session.AddEvent("fallback_off", fmt.Sprintf("meeting is reachable again after %s, switched back", time.Since(*since).Round(time.Second)))
+		if session.dumped() {
+			applyDumpSlate(driver, session)
+			return nil
+		}
+		err := hideSlate(driver, true)
+		if err != nil {
+			log.Printf("Warning: Failed to take the fallback down: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *Session) fallbackStartedAt() *time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fallbackSince
+}
+
+func (s *Session) setFallbackStartedAt(at *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallbackSince = at
+}
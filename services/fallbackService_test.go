@@ -0,0 +1,50 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Fallback Service", func() {
+	eventTypes := func(session *services.Session) []string {
+		var types []string
+		for _, event := range session.Snapshot().Events {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	It("should do nothing for broadcasts without a fallback", func() {
+		session := services.NewSession(&models.BroadcasterRequest{})
+		driver := &fakeDriver{}
+
+		Expect(services.UpdateFallback(driver, session, "health check failed")).To(Succeed())
+		Expect(driver.scripts).To(BeEmpty())
+		Expect(session.Snapshot().FallbackSince).To(BeNil())
+	})
+
+	It("should switch to the fallback while the meeting is unreachable and back once it recovers", func() {
+		session := services.NewSession(&models.BroadcasterRequest{
+			Fallback: &models.Slate{Text: "Technical difficulties", VideoURL: "https://cdn.example.com/loop.mp4"},
+		})
+		driver := &fakeDriver{}
+
+		Expect(services.UpdateFallback(driver, session, "health check failed: 502 Bad Gateway")).To(Succeed())
+		Expect(session.Snapshot().FallbackSince).NotTo(BeNil())
+		Expect(services.UpdateFallback(driver, session, "health check failed: 502 Bad Gateway")).To(Succeed())
+		Expect(driver.scripts).To(HaveLen(2))
+		Expect(driver.scripts[1]).To(ContainSubstring("video_url"))
+
+		Expect(services.UpdateFallback(driver, session, "")).To(Succeed())
+		Expect(session.Snapshot().FallbackSince).To(BeNil())
+		Expect(driver.scripts).To(HaveLen(3))
+		Expect(driver.scripts[2]).To(ContainSubstring("spoutbreeze-slate-leaving"))
+		Expect(services.UpdateFallback(driver, session, "")).To(Succeed())
+		Expect(driver.scripts).To(HaveLen(3))
+
+		Expect(eventTypes(session)).To(Equal([]string{"fallback_on", "fallback_off"}))
+	})
+})
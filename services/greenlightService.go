@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+const defaultGreenlightDisplayName = "SpoutBreeze Broadcaster"
+
+// Timings of the Greenlight join, shortened by the tests that drive it with a
+// fake browser.
+var (
+	greenlightJoinTimeout  = 10 * time.Minute
+	greenlightPollInterval = 3 * time.Second
+)
+
+// Selectors are listed Greenlight 3 first, then Greenlight 2.
+var (
+	greenlightNameSelectors = []string{
+		"#joinFormName",
+		"input[id$='_join_name']",
+		"input[name$='[join_name]']",
+		"input[placeholder*='name' i]",
+	}
+	greenlightAccessCodeSelectors = []string{
+		"#joinFormAccessCode",
+		"#room_access_code",
+		"input[name='room[access_code]']",
+		"input[placeholder*='access code' i]",
+	}
+	greenlightJoinButtonSelectors = []string{
+		"form button[type='submit']",
+		"#room-join",
+		"form input[type='submit']",
+	}
+)
+
+const (
+	greenlightJoined            = "joined"
+	greenlightInvalidAccessCode = "invalid_access_code"
+	greenlightWaiting           = "waiting"
+)
+
+// greenlightStatusScript reports whether the browser has left Greenlight for
+// the BBB client, or whether Greenlight rejected the access code.
+const greenlightStatusScript = `
+var url = window.location.href.toLowerCase();
+if (url.indexOf("/html5client/") !== -1 || url.indexOf("/bigbluebutton/api/join") !== -1) {
+	return "joined";
+}
+var text = ((document.body && document.body.innerText) || "").toLowerCase();
+if (text.indexOf("incorrect access code") !== -1 || text.indexOf("invalid access code") !== -1 || text.indexOf("wrong access code") !== -1) {
+	return "invalid_access_code";
+}
+return "waiting";
+`
+
+// JoinGreenlightRoom opens a Greenlight room link, fills in the display name
+// and access code, and clicks Join. Greenlight 2 asks for the access code on
+// its own page before the name form, while Greenlight 3 shows both fields on
+// one form, so fields are filled as they appear and Join is clicked on each
+// form until the BBB client loads. Fields the browser already filled in are
+// kept. If the meeting has not started yet, Greenlight keeps the bot on its
+// waiting page and redirects it once the meeting is running.
+func JoinGreenlightRoom(driver selenium.WebDriver, request *models.BroadcasterRequest) error {
+	err := driver.Get(request.GreenlightRoomURL)
+	if err != nil {
+		return fmt.Errorf("failed to navigate to Greenlight room: %w", err)
+	}
+	time.Sleep(greenlightPollInterval)
+
+	displayName := request.DisplayName
+	if displayName == "" {
+		displayName = defaultGreenlightDisplayName
+	}
+
+	deadline := time.Now().Add(greenlightJoinTimeout)
+	for time.Now().Before(deadline) {
+		switch greenlightStatus(driver) {
+		case greenlightJoined:
+			return nil
+		case greenlightInvalidAccessCode:
+			return &SessionError{Reason: models.ReasonInvalidAccessCode, Err: errors.New("greenlight rejected the access code")}
+		}
+
+		formShown := false
+		if request.AccessCode != "" && fillGreenlightField(driver, greenlightAccessCodeSelectors, request.AccessCode) {
+			formShown = true
+		}
+		if fillGreenlightField(driver, greenlightNameSelectors, displayName) {
+			formShown = true
+		}
+		if formShown {
+			button := findVisibleElement(driver, greenlightJoinButtonSelectors)
+			if button == nil {
+				return &SessionError{Reason: models.ReasonGreenlightJoinFailed, Err: errors.New("join button not found on Greenlight page")}
+			}
+			_, err = driver.ExecuteScript("arguments[0].click();", []interface{}{button})
+			if err != nil {
+				log.Printf("Warning: Failed to click Greenlight join button: %v", err)
+			}
+		}
+
+		time.Sleep(greenlightPollInterval)
+	}
+
+	return &SessionError{Reason: models.ReasonGreenlightJoinFailed, Err: errors.New("timed out waiting for Greenlight to open the meeting")}
+}
+
+func greenlightStatus(driver selenium.WebDriver) string {
+	result, err := driver.ExecuteScript(greenlightStatusScript, nil)
+	if err != nil {
+		log.Printf("Warning: Failed to check Greenlight page: %v", err)
+		return greenlightWaiting
+	}
+	status, _ := result.(string)
+	return status
+}
+
+// fillGreenlightField types value into the first visible field matching one
+// of the selectors, unless it already holds a value. It reports whether the
+// field is on the page and filled in.
+func fillGreenlightField(driver selenium.WebDriver, selectors []string, value string) bool {
+	field := findVisibleElement(driver, selectors)
+	if field == nil {
+		return false
+	}
+	current, err := field.GetAttribute("value")
+	if err == nil && current != "" {
+		return true
+	}
+	if err := field.SendKeys(value); err != nil {
+		log.Printf("Warning: Failed to fill Greenlight field: %v", err)
+		return false
+	}
+	return true
+}
+
+func findVisibleElement(driver selenium.WebDriver, selectors []string) selenium.WebElement {
+	for _, selector := range selectors {
+		elements, err := driver.FindElements(selenium.ByCSSSelector, selector)
+		if err != nil {
+			continue
+		}
+		for _, element := range elements {
+			if displayed, err := element.IsDisplayed(); err == nil && displayed {
+				return element
+			}
+		}
+	}
+	return nil
+}
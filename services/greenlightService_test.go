@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tebeka/selenium"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// fakeGreenlight is a Greenlight room page. Each form lists the selectors of
+// its fields and of its Join button; clicking Join with every field filled
+// in moves on to the next form, and past the last one into the BBB client.
+type fakeGreenlight struct {
+	selenium.WebDriver
+	mu     sync.Mutex
+	forms  [][]string
+	form   int
+	values map[string]string
+	clicks int
+}
+
+// fakeField is an element of a fakeGreenlight form, known by its selector.
+type fakeField struct {
+	selenium.WebElement
+	page     *fakeGreenlight
+	selector string
+}
+
+func (f *fakeField) IsDisplayed() (bool, error) {
+	return true, nil
+}
+
+func (f *fakeField) GetAttribute(name string) (string, error) {
+	f.page.mu.Lock()
+	defer f.page.mu.Unlock()
+	return f.page.values[f.selector], nil
+}
+
+func (f *fakeField) SendKeys(keys string) error {
+	f.page.mu.Lock()
+	defer f.page.mu.Unlock()
+	f.page.values[f.selector] += keys
+	return nil
+}
+
+func (p *fakeGreenlight) Get(url string) error {
+	return nil
+}
+
+func (p *fakeGreenlight) FindElements(by string, selector string) ([]selenium.WebElement, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.form < len(p.forms) {
+		for _, field := range p.forms[p.form] {
+			if field == selector {
+				return []selenium.WebElement{&fakeField{page: p, selector: selector}}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (p *fakeGreenlight) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !strings.Contains(script, "click()") {
+		if p.form == len(p.forms) {
+			return "joined", nil
+		}
+		return "waiting", nil
+	}
+	p.clicks++
+	for _, field := range p.forms[p.form] {
+		if !strings.Contains(field, "submit") && field != "#room-join" && p.values[field] == "" {
+			return nil, nil
+		}
+	}
+	p.form++
+	return nil, nil
+}
+
+func (p *fakeGreenlight) Values() map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	values := map[string]string{}
+	for selector, value := range p.values {
+		values[selector] = value
+	}
+	return values
+}
+
+var _ = Describe("Greenlight Service", func() {
+	request := &models.BroadcasterRequest{GreenlightRoomURL: "https://greenlight.example.com/rooms/abc-def-ghi/join", AccessCode: "123456"}
+
+	BeforeEach(func() {
+		DeferCleanup(services.ShortenGreenlightTimings(time.Millisecond, time.Second))
+	})
+
+	It("should fill in the access code page, then the name form of Greenlight 2", func() {
+		page := &fakeGreenlight{values: map[string]string{}, forms: [][]string{
+			{"#room_access_code", "#room-join"},
+			{"input[id$='_join_name']", "#room-join"},
+		}}
+
+		Expect(services.JoinGreenlightRoom(page, request)).To(Succeed())
+		Expect(page.Values()).To(Equal(map[string]string{
+			"#room_access_code":       "123456",
+			"input[id$='_join_name']": "SpoutBreeze Broadcaster",
+		}))
+		Expect(page.clicks).To(Equal(2))
+	})
+
+	It("should fill in the single join form of Greenlight 3", func() {
+		page := &fakeGreenlight{values: map[string]string{}, forms: [][]string{
+			{"#joinFormName", "#joinFormAccessCode", "form button[type='submit']"},
+		}}
+
+		Expect(services.JoinGreenlightRoom(page, request)).To(Succeed())
+		Expect(page.Values()).To(Equal(map[string]string{
+			"#joinFormName":       "SpoutBreeze Broadcaster",
+			"#joinFormAccessCode": "123456",
+		}))
+		Expect(page.clicks).To(Equal(1))
+	})
+
+	It("should click Join when the browser already filled in the form", func() {
+		page := &fakeGreenlight{values: map[string]string{"#joinFormName": "Lecture Stream"}, forms: [][]string{
+			{"#joinFormName", "form button[type='submit']"},
+		}}
+
+		Expect(services.JoinGreenlightRoom(page, &models.BroadcasterRequest{GreenlightRoomURL: request.GreenlightRoomURL})).To(Succeed())
+		Expect(page.Values()).To(Equal(map[string]string{"#joinFormName": "Lecture Stream"}))
+		Expect(page.clicks).To(Equal(1))
+	})
+})
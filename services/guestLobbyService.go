@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+const (
+	defaultGuestApprovalTimeout = 10 * time.Minute
+	guestLobbyPollInterval      = 5 * time.Second
+)
+
+const (
+	guestLobbyNone    = ""
+	guestLobbyWaiting = "waiting"
+	guestLobbyDenied  = "denied"
+)
+
+// guestLobbyScript inspects the page for the BBB guest wait screen, which is
+// served from /guestWait on BBB 2.x and rendered in-client on later versions.
+const guestLobbyScript = `
+var url = window.location.href.toLowerCase();
+var text = ((document.body && document.body.innerText) || "").toLowerCase();
+var isGuestPage = url.indexOf("guestwait") !== -1 || text.indexOf("guest") !== -1;
+if (isGuestPage && (text.indexOf("guest denied") !== -1 || text.indexOf("denied of joining") !== -1 || text.indexOf("denied access") !== -1)) {
+	return "denied";
+}
+if (url.indexOf("guestwait") !== -1 || text.indexOf("wait for a moderator to approve") !== -1) {
+	return "waiting";
+}
+return "";
+`
+
+func guestApprovalTimeout(request *models.BroadcasterRequest) time.Duration {
+	if request.GuestApprovalTimeoutSeconds > 0 {
+		return time.Duration(request.GuestApprovalTimeoutSeconds) * time.Second
+	}
+	return defaultGuestApprovalTimeout
+}
+
+func guestLobbyStatus(driver selenium.WebDriver) string {
+	result, err := driver.ExecuteScript(guestLobbyScript, nil)
+	if err != nil {
+		log.Printf("Warning: Failed to check guest lobby: %v", err)
+		return guestLobbyNone
+	}
+	status, _ := result.(string)
+	return status
+}
+
+// WaitForGuestApproval blocks while the bot sits in the BBB guest lobby. It
+// returns nil once the bot is let in, or a SessionError if it is denied or
+// nobody approves it before the timeout.
+func WaitForGuestApproval(driver selenium.WebDriver, session *Session, timeout time.Duration) error {
+	status := guestLobbyStatus(driver)
+	if status == guestLobbyNone {
+		return nil
+	}
+
+	session.SetState(models.SessionStateWaitingForApproval, "")
+	deadline := time.Now().Add(timeout)
+
+	for {
+		switch status {
+		case guestLobbyDenied:
+			return &SessionError{Reason: models.ReasonGuestDenied, Err: errors.New("moderator denied the guest join request")}
+		case guestLobbyNone:
+			log.Printf("Session %s approved by moderator", session.ID)
+			session.AddEvent("guest_approved", "moderator approved the bot")
+			session.SetState(models.SessionStateStarting, "")
+			// Give the BBB client time to load after leaving the lobby
+			time.Sleep(5 * time.Second)
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return &SessionError{Reason: models.ReasonGuestApprovalTimeout, Err: errors.New("no moderator approved the bot in time")}
+		}
+		time.Sleep(min(guestLobbyPollInterval, remaining))
+		status = guestLobbyStatus(driver)
+	}
+}
@@ -0,0 +1,67 @@
+package services_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tebeka/selenium"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// fakeDriver answers ExecuteScript calls from a canned sequence of results;
+// any other WebDriver call panics through the nil embedded interface.
+type fakeDriver struct {
+	selenium.WebDriver
+	scriptResults []interface{}
+	scripts       []string
+}
+
+func (d *fakeDriver) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	d.scripts = append(d.scripts, script)
+	if len(d.scriptResults) == 0 {
+		return nil, nil
+	}
+	result := d.scriptResults[0]
+	if len(d.scriptResults) > 1 {
+		d.scriptResults = d.scriptResults[1:]
+	}
+	return result, nil
+}
+
+var _ = Describe("Guest Lobby Service", func() {
+	var session *services.Session
+
+	BeforeEach(func() {
+		session = services.NewSession(&models.BroadcasterRequest{})
+	})
+
+	It("should return immediately when the bot is not in the lobby", func() {
+		driver := &fakeDriver{scriptResults: []interface{}{""}}
+
+		Expect(services.WaitForGuestApproval(driver, session, time.Minute)).To(Succeed())
+		Expect(session.State()).To(Equal(models.SessionStateStarting))
+	})
+
+	It("should fail with guest_denied when the moderator denies the bot", func() {
+		driver := &fakeDriver{scriptResults: []interface{}{"denied"}}
+
+		err := services.WaitForGuestApproval(driver, session, time.Minute)
+
+		var sessionErr *services.SessionError
+		Expect(err).To(BeAssignableToTypeOf(sessionErr))
+		Expect(err.(*services.SessionError).Reason).To(Equal(models.ReasonGuestDenied))
+		Expect(session.State()).To(Equal(models.SessionStateWaitingForApproval))
+	})
+
+	It("should fail with guest_approval_timeout when nobody approves the bot", func() {
+		driver := &fakeDriver{scriptResults: []interface{}{"waiting"}}
+
+		err := services.WaitForGuestApproval(driver, session, 10*time.Millisecond)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*services.SessionError).Reason).To(Equal(models.ReasonGuestApprovalTimeout))
+	})
+})
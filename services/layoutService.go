@@ -0,0 +1,267 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+// ErrHiddenByCleanFeed is returned for layout actions that show parts of the
+// BBB client the clean feed hides.
+var ErrHiddenByCleanFeed = errors.New("layout action shows a panel the clean feed hides")
+
+// cleanFeedCSS hides every part of the BBB client that is not meeting
+// content: navbar, action bar, side panels, notifications and toasts.
+// Selectors cover the BBB 2.6 and 2.7 markup.
+const cleanFeedCSS = `
+header,
+[data-test="navBar"],
+section[aria-label="Actions bar"],
+section[aria-label="Actions Bar"],
+[data-test="actionsBar"],
+#sidebar-navigation,
+#sidebar-content,
+[aria-label="Users and messages"],
+[data-test="notificationBannerBar"],
+[class*="notificationsBar"],
+[data-test="talkingIndicator"],
+[data-test="presentationToolbarWrapper"],
+[data-test="whiteboardToolbar"],
+.Toastify,
+.Toastify__toast-container,
+[class*="toastContainer"],
+[role="alert"] {
+	display: none !important;
+}
+`
+
+// cleanFeedScript installs cleanFeedCSS as a style element and keeps it in
+// place when BBB re-renders the document head. It is idempotent, a reloaded
+// page simply gets a fresh install.
+const cleanFeedScript = `
+var css = arguments[0];
+var install = function () {
+	if (!document.getElementById("spoutbreeze-clean-feed")) {
+		var style = document.createElement("style");
+		style.id = "spoutbreeze-clean-feed";
+		style.textContent = css;
+		document.head.appendChild(style);
+	}
+};
+install();
+if (!window.__spoutbreezeCleanFeedObserver) {
+	window.__spoutbreezeCleanFeedObserver = new MutationObserver(install);
+	window.__spoutbreezeCleanFeedObserver.observe(document.head, { childList: true });
+}
+`
+
+// applyLayout injects the requested layout into the BBB client. It is called
+// after every join and on each monitor tick, so it must stay idempotent.
+// Portrait output always uses the clean feed, the BBB interface does not fit
+// a 9:16 picture. Audio-only bots stop drawing video instead.
+func applyLayout(driver selenium.WebDriver, request *models.BroadcasterRequest) {
+	if request.Quality == models.QualityAudioOnly {
+		applyAudioOnly(driver)
+		return
+	}
+	if !usesCleanFeed(request) {
+		return
+	}
+	_, err := driver.ExecuteScript(cleanFeedScript, []interface{}{cleanFeedCSS})
+	if err != nil {
+		log.Printf("Warning: Failed to apply clean feed layout: %v", err)
+	}
+	if request.Orientation == models.OrientationPortrait {
+		applyPortraitLayout(driver)
+	}
+}
+
+func usesCleanFeed(request *models.BroadcasterRequest) bool {
+	if request.Quality == models.QualityAudioOnly {
+		return false
+	}
+	return request.Layout == models.LayoutClean || request.Orientation == models.OrientationPortrait
+}
+
+// checkLayoutAction rejects the actions that would open the side panels
+// under the clean feed, where they would silently stay hidden.
+func checkLayoutAction(request *models.BroadcasterRequest, action string) error {
+	if !usesCleanFeed(request) {
+		return nil
+	}
+	if action == models.LayoutActionShowChat || action == models.LayoutActionShowUserList {
+		return fmt.Errorf("%w: %s", ErrHiddenByCleanFeed, action)
+	}
+	return nil
+}
+
+// layoutClassCSS backs the layout actions BBB has no setting for. The active
+// one is selected by a class on the document element.
+const layoutClassCSS = `
+html.spoutbreeze-presentation-fullscreen [data-test="presentationContainer"],
+html.spoutbreeze-presentation-fullscreen section[aria-label="Presentation"] {
+	position: fixed !important;
+	inset: 0 !important;
+	width: 100vw !important;
+	height: 100vh !important;
+	z-index: 1000 !important;
+	background: #000 !important;
+}
+html.spoutbreeze-speaker-focus:has([data-test="webcamItemTalkUser"]) [data-test="webcamItem"] {
+	display: none !important;
+}
+`
+
+const layoutClassScript = `
+if (!document.getElementById("spoutbreeze-layout")) {
+	var style = document.createElement("style");
+	style.id = "spoutbreeze-layout";
+	style.textContent = arguments[0];
+	document.head.appendChild(style);
+}
+var root = document.documentElement;
+root.classList.remove("spoutbreeze-presentation-fullscreen", "spoutbreeze-speaker-focus");
+if (arguments[1]) {
+	root.classList.add(arguments[1]);
+}
+`
+
+// BBB layout names as shown in the client's layout modal.
+var bbbLayoutLabels = map[string]string{
+	models.LayoutActionSmartLayout:  "Smart layout",
+	models.LayoutActionFocusWebcams: "Focus on video",
+	models.LayoutActionSpeakerFocus: "Focus on video",
+}
+
+const (
+	usersAndMessagesToggleXPath = "//button[@aria-label='Users and messages toggle']"
+	showChatXPath               = "//*[@data-test='chatButton']"
+	hideChatXPath               = "//button[@data-test='hidePublicChat' or contains(@aria-label, 'Hide Public Chat')]"
+)
+
+// ApplyLayoutAction runs a layout action against the live browser of a
+// session. The action is remembered and replayed after the bot rejoins.
+func ApplyLayoutAction(sessionID string, action string) error {
+	session, ok := GetSession(sessionID)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	err := checkLayoutAction(session.Request, action)
+	if err != nil {
+		return err
+	}
+	driver := session.Driver()
+	if driver == nil || session.State() != models.SessionStateLive {
+		return ErrSessionNotLive
+	}
+
+	err = runLayoutAction(driver, action)
+	if err != nil {
+		return err
+	}
+	session.setLayoutAction(action)
+	session.AddEvent("layout_changed", action)
+	return nil
+}
+
+func (s *Session) setLayoutAction(action string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.layoutAction = action
+}
+
+func (s *Session) LayoutAction() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.layoutAction
+}
+
+func runLayoutAction(driver selenium.WebDriver, action string) error {
+	switch action {
+	case models.LayoutActionPresentationFullscreen:
+		return setLayoutClass(driver, "spoutbreeze-presentation-fullscreen")
+	case models.LayoutActionSmartLayout, models.LayoutActionFocusWebcams:
+		if err := setLayoutClass(driver, ""); err != nil {
+			return err
+		}
+		return selectBBBLayout(driver, bbbLayoutLabels[action])
+	case models.LayoutActionSpeakerFocus:
+		if err := selectBBBLayout(driver, bbbLayoutLabels[action]); err != nil {
+			return err
+		}
+		return setLayoutClass(driver, "spoutbreeze-speaker-focus")
+	case models.LayoutActionHideChat:
+		return clickXPath(driver, hideChatXPath)
+	case models.LayoutActionShowChat:
+		if err := setUserListOpen(driver, true); err != nil {
+			return err
+		}
+		return clickXPath(driver, showChatXPath)
+	case models.LayoutActionHideUserList:
+		return setUserListOpen(driver, false)
+	case models.LayoutActionShowUserList:
+		return setUserListOpen(driver, true)
+	}
+	return fmt.Errorf("unknown layout action %q", action)
+}
+
+func setLayoutClass(driver selenium.WebDriver, class string) error {
+	_, err := driver.ExecuteScript(layoutClassScript, []interface{}{layoutClassCSS, class})
+	if err != nil {
+		return fmt.Errorf("failed to apply layout: %w", err)
+	}
+	return nil
+}
+
+// selectBBBLayout picks a layout in the BBB layout modal, opened from the
+// actions menu.
+func selectBBBLayout(driver selenium.WebDriver, label string) error {
+	steps := []string{
+		"//button[@data-test='actionsButton' or @aria-label='Actions']",
+		"//li[@data-test='layoutModal' or contains(., 'Layout')]",
+		fmt.Sprintf("//button[contains(@aria-label, '%s') or contains(., '%s')]", label, label),
+		"//button[@data-test='layoutModalConfirm' or contains(., 'Confirm')]",
+	}
+	for _, xpath := range steps {
+		if err := clickXPath(driver, xpath); err != nil {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+	return nil
+}
+
+func setUserListOpen(driver selenium.WebDriver, open bool) error {
+	toggle, err := driver.FindElement(selenium.ByXPATH, usersAndMessagesToggleXPath)
+	if err != nil {
+		return fmt.Errorf("users and messages toggle not found: %w", err)
+	}
+	expanded, _ := toggle.GetAttribute("aria-expanded")
+	if (expanded == "true") == open {
+		return nil
+	}
+	_, err = driver.ExecuteScript("arguments[0].click();", []interface{}{toggle})
+	if err != nil {
+		return fmt.Errorf("failed to click users and messages toggle: %w", err)
+	}
+	time.Sleep(time.Second)
+	return nil
+}
+
+// clickXPath clicks through JavaScript so that elements hidden by the clean
+// feed layout can still be used.
+func clickXPath(driver selenium.WebDriver, xpath string) error {
+	element, err := driver.FindElement(selenium.ByXPATH, xpath)
+	if err != nil {
+		return fmt.Errorf("element %s not found: %w", xpath, err)
+	}
+	_, err = driver.ExecuteScript("arguments[0].click();", []interface{}{element})
+	if err != nil {
+		return fmt.Errorf("failed to click %s: %w", xpath, err)
+	}
+	return nil
+}
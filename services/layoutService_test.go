@@ -0,0 +1,43 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Layout Service", func() {
+	Describe("ApplyLayoutAction", func() {
+		It("should return ErrSessionNotFound for unknown sessions", func() {
+			err := services.ApplyLayoutAction("unknown", models.LayoutActionSmartLayout)
+			Expect(err).To(MatchError(services.ErrSessionNotFound))
+		})
+
+		It("should return ErrSessionNotLive when the bot has no browser yet", func() {
+			session := services.NewSession(&models.BroadcasterRequest{})
+
+			err := services.ApplyLayoutAction(session.ID, models.LayoutActionSmartLayout)
+			Expect(err).To(MatchError(services.ErrSessionNotLive))
+			Expect(session.LayoutAction()).To(BeEmpty())
+		})
+
+		DescribeTable("panels under the clean feed",
+			func(request *models.BroadcasterRequest, action string, hidden bool) {
+				session := services.NewSession(request)
+
+				err := services.ApplyLayoutAction(session.ID, action)
+				if hidden {
+					Expect(err).To(MatchError(services.ErrHiddenByCleanFeed))
+				} else {
+					Expect(err).To(MatchError(services.ErrSessionNotLive))
+				}
+			},
+			Entry("show chat on the clean feed", &models.BroadcasterRequest{Layout: models.LayoutClean}, models.LayoutActionShowChat, true),
+			Entry("show user list in portrait", &models.BroadcasterRequest{Orientation: models.OrientationPortrait}, models.LayoutActionShowUserList, true),
+			Entry("hide chat on the clean feed", &models.BroadcasterRequest{Layout: models.LayoutClean}, models.LayoutActionHideChat, false),
+			Entry("show chat on the default layout", &models.BroadcasterRequest{}, models.LayoutActionShowChat, false),
+		)
+	})
+})
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+const (
+	maxRejoinAttempts        = 3
+	rejoinAttemptsResetAfter = 5 * time.Minute
+)
+
+// Timings of the meeting watch, shortened by the tests that drive it with a
+// fake browser.
+var (
+	meetingScreenPollInterval = 5 * time.Second
+	healthCheckInterval       = 20 * time.Second
+	fallbackSceneHold         = 5 * time.Second
+	clientRecoveryDeadline    = 30 * time.Second
+	pageLoadWait              = 5 * time.Second
+)
+
+const (
+	meetingScreenNone           = ""
+	meetingScreenEnded          = "ended"
+	meetingScreenRemoved        = "removed"
+	meetingScreenConnectionLost = "connection_lost"
+	meetingScreenAudioModal     = "audio_modal"
+)
+
+// The BBB client's own elements the meeting screens are told from, so that
+// the same words in chat, captions or slides are not mistaken for them. The
+// meeting ended modal also tells a bot that was removed, and the
+// notification banner shows the client's connection status.
+var (
+	meetingEndedSelectors     = []string{`[data-test="meetingEndedModalTitle"]`, `[data-test="meetingEndedModal"]`}
+	connectionStatusSelectors = []string{`[data-test="notificationBannerBar"]`}
+	audioModalSelectors       = []string{`button[aria-label='Listen only']`, `[data-test="listenOnlyBtn"]`}
+)
+
+// meetingScreenScript returns the text of the elements found for the
+// selectors in arguments[0], by selector.
+const meetingScreenScript = `
+var found = {};
+arguments[0].forEach(function (selector) {
+	var element = document.querySelector(selector);
+	if (element) {
+		found[selector] = element.innerText || "";
+	}
+});
+return found;
+`
+
+// fallbackSceneScript covers the BBB client with a plain full-page card so
+// that error screens are not broadcast.
+const fallbackSceneScript = `
+var scene = document.getElementById("spoutbreeze-fallback-scene");
+if (!scene) {
+	scene = document.createElement("div");
+	scene.id = "spoutbreeze-fallback-scene";
+	scene.style.cssText = "position:fixed;inset:0;z-index:2147483647;display:flex;align-items:center;justify-content:center;background:#000;color:#fff;font:600 48px sans-serif;text-align:center;";
+	document.body.appendChild(scene);
+}
+scene.textContent = arguments[0];
+`
+
+func detectMeetingScreen(driver selenium.WebDriver) string {
+	var selectors []string
+	selectors = append(selectors, meetingEndedSelectors...)
+	selectors = append(selectors, connectionStatusSelectors...)
+	selectors = append(selectors, audioModalSelectors...)
+	result, err := driver.ExecuteScript(meetingScreenScript, []interface{}{selectors})
+	if err != nil {
+		log.Printf("Warning: Failed to observe meeting screen: %v", err)
+		return meetingScreenNone
+	}
+	found, _ := result.(map[string]interface{})
+	return classifyMeetingScreen(found)
+}
+
+// classifyMeetingScreen tells the screen the client shows from the elements
+// meetingScreenScript found.
+func classifyMeetingScreen(found map[string]interface{}) string {
+	for _, selector := range meetingEndedSelectors {
+		text, ok := found[selector].(string)
+		if !ok {
+			continue
+		}
+		text = strings.ToLower(text)
+		if strings.Contains(text, "removed") || strings.Contains(text, "ejected") {
+			return meetingScreenRemoved
+		}
+		return meetingScreenEnded
+	}
+	for _, selector := range connectionStatusSelectors {
+		text, _ := found[selector].(string)
+		text = strings.ToLower(text)
+		// "Connecting", "Reconnecting", "Connection lost, trying to reconnect" or "Offline"
+		if strings.Contains(text, "connecting") || strings.Contains(text, "connection lost") || strings.Contains(text, "offline") {
+			return meetingScreenConnectionLost
+		}
+	}
+	for _, selector := range audioModalSelectors {
+		if _, ok := found[selector]; ok {
+			return meetingScreenAudioModal
+		}
+	}
+	return meetingScreenNone
+}
+
+func showFallbackScene(driver selenium.WebDriver, message string) {
+	_, err := driver.ExecuteScript(fallbackSceneScript, []interface{}{message})
+	if err != nil {
+		log.Printf("Warning: Failed to show fallback scene: %v", err)
+	}
+}
+
+// endBroadcast holds the ended slate, or the ended scene briefly, on the
+// stream before teardown.
+func endBroadcast(driver selenium.WebDriver, session *Session) {
+	if !showEndedSlate(driver, session.Request) {
+		showFallbackScene(driver, "This broadcast has ended")
+		time.Sleep(fallbackSceneHold)
+	}
+	session.SetState(models.SessionStateEnded, models.ReasonMeetingEnded)
+}
+
+// monitorMeeting watches the live meeting until it ends. The bot tears down
+// when the meeting ends or it is removed. When the client shows its
+// reconnecting overlay or falls back to the audio modal, the join steps are
+// rerun and the client gets clientRecoveryDeadline to recover before the page
+// is reloaded and the meeting rejoined. While the health check fails or the
+// client is recovering, the broadcast's fallback is on air, and rejoins wait
+// for the health check to pass again. A requested restart stops it with
+// errRestartRequested.
+func monitorMeeting(driver selenium.WebDriver, session *Session) error {
+	// Create HTTP client
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	var meetingWasRunning bool
+	var lastHealthCheck, lastRejoin, recoveringSince time.Time
+	var healthErr error
+	rejoinAttempts := 0
+	director := newAutoDirector(session.Request.AutoDirector)
+
+	for {
+		if session.restartRequested() {
+			return errRestartRequested
+		}
+
+		screen := detectMeetingScreen(driver)
+		switch screen {
+		case meetingScreenEnded:
+			log.Println("Meeting has ended, terminating session...")
+			session.AddEvent("meeting_screen", "meeting ended screen detected")
+			endBroadcast(driver, session)
+			return nil
+
+		case meetingScreenRemoved:
+			session.AddEvent("meeting_screen", "removed from meeting screen detected")
+			showFallbackScene(driver, "This broadcast has ended")
+			time.Sleep(fallbackSceneHold)
+			return &SessionError{Reason: models.ReasonRemovedFromMeeting, Err: errors.New("bot was removed from the meeting")}
+
+		case meetingScreenConnectionLost, meetingScreenAudioModal:
+			if recoveringSince.IsZero() {
+				recoveringSince = time.Now()
+				session.AddEvent("reconnecting", "client shows "+screen+", waiting for it to recover")
+			}
+			if screen == meetingScreenAudioModal {
+				// The client reconnected but dropped the bot back to the audio modal
+				runClientJoinSteps(driver)
+			}
+			if time.Since(recoveringSince) < clientRecoveryDeadline {
+				break
+			}
+			if session.Request.Fallback != nil && healthErr != nil {
+				// Rejoining cannot work while BBB is unreachable, the fallback holds the stream
+				break
+			}
+
+			// The client did not recover by itself, reload the page and rejoin
+			if !lastRejoin.IsZero() && time.Since(lastRejoin) > rejoinAttemptsResetAfter {
+				rejoinAttempts = 0
+			}
+			if rejoinAttempts >= maxRejoinAttempts {
+				return &SessionError{Reason: models.ReasonConnectionLost, Err: fmt.Errorf("client did not recover after %d rejoin attempts", rejoinAttempts)}
+			}
+			rejoinAttempts++
+			lastRejoin = time.Now()
+			recoveringSince = time.Time{}
+			session.AddEvent("rejoin", fmt.Sprintf("client did not recover within %s, reloading and rejoining (attempt %d/%d)", clientRecoveryDeadline, rejoinAttempts, maxRejoinAttempts))
+			err := joinMeeting(driver, session)
+			if err != nil {
+				return err
+			}
+			applyDumpSlate(driver, session)
+			if director != nil {
+				director.reset()
+			}
+			continue
+
+		default:
+			if !recoveringSince.IsZero() {
+				session.AddEvent("recovered", fmt.Sprintf("client recovered after %s", time.Since(recoveringSince).Round(time.Second)))
+				recoveringSince = time.Time{}
+			}
+			// Reinstall the layout, overlays and slate in case the client reloaded the page on its own
+			applyLayout(driver, session.Request)
+			applyOverlays(driver, session.Overlays())
+			applyDumpSlate(driver, session)
+			checkWHIP(driver, session)
+			if director != nil {
+				director.tick(driver, session)
+			}
+		}
+
+		// Check session status every 20 seconds, and on every tick while it fails
+		if healthErr != nil || time.Since(lastHealthCheck) >= healthCheckInterval {
+			lastHealthCheck = time.Now()
+			refreshDestinationStatuses(session)
+			meetingRunning, err := IsMeetingRunning(client, session.Request.BBBHealthCheckURL)
+			healthErr = err
+			if err != nil {
+				log.Printf("%v", err)
+			} else {
+				if meetingRunning != meetingWasRunning {
+					if meetingRunning {
+						log.Println("Meeting is still running, keeping session alive...")
+					} else {
+						log.Println("Meeting has ended, terminating session...")
+					}
+				}
+				if meetingWasRunning && !meetingRunning {
+					session.AddEvent("health_check", "meeting is no longer running")
+					endBroadcast(driver, session)
+					return nil
+				}
+				meetingWasRunning = meetingRunning
+			}
+		}
+
+		cause := ""
+		switch {
+		case healthErr != nil:
+			cause = "health check failed: " + healthErr.Error()
+		case !recoveringSince.IsZero():
+			cause = "client shows " + screen
+		}
+		err := UpdateFallback(driver, session, cause)
+		if err != nil {
+			return err
+		}
+
+		time.Sleep(meetingScreenPollInterval)
+	}
+}
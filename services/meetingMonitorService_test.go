@@ -0,0 +1,232 @@
+package services_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tebeka/selenium"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// fakePage is a browser showing the BBB client, its elements given by
+// selector. It answers the meeting screen script from them, counts page
+// loads and finds none of the elements the join steps click.
+type fakePage struct {
+	selenium.WebDriver
+	mu          sync.Mutex
+	elements    map[string]string
+	navigations int
+}
+
+func (p *fakePage) show(elements map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elements = elements
+}
+
+func (p *fakePage) Navigations() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.navigations
+}
+
+func (p *fakePage) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(args) == 1 {
+		if selectors, ok := args[0].([]string); ok {
+			found := map[string]interface{}{}
+			for _, selector := range selectors {
+				if text, ok := p.elements[selector]; ok {
+					found[selector] = text
+				}
+			}
+			return found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *fakePage) FindElement(by string, value string) (selenium.WebElement, error) {
+	return nil, errors.New("no such element")
+}
+
+func (p *fakePage) Get(url string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.navigations++
+	return nil
+}
+
+var _ = Describe("Meeting Monitor Service", func() {
+	Describe("IsMeetingRunning", func() {
+		var client *http.Client
+
+		BeforeEach(func() {
+			client = &http.Client{Timeout: time.Second}
+		})
+
+		serve := func(body string) *httptest.Server {
+			return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(body))
+			}))
+		}
+
+		It("should report a running meeting", func() {
+			server := serve(`<response><returncode>SUCCESS</returncode><running>true</running></response>`)
+			defer server.Close()
+
+			running, err := services.IsMeetingRunning(client, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running).To(BeTrue())
+		})
+
+		It("should report a meeting that is not running", func() {
+			server := serve(`<response><returncode>SUCCESS</returncode><running>false</running></response>`)
+			defer server.Close()
+
+			running, err := services.IsMeetingRunning(client, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running).To(BeFalse())
+		})
+
+		It("should return an error when the response is not XML", func() {
+			server := serve(`not xml`)
+			defer server.Close()
+
+			_, err := services.IsMeetingRunning(client, server.URL)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should report a meeting BBB does not know as not running", func() {
+			server := serve(`<response><returncode>FAILED</returncode><messageKey>notFound</messageKey></response>`)
+			defer server.Close()
+
+			running, err := services.IsMeetingRunning(client, server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running).To(BeFalse())
+		})
+
+		It("should return an error when a proxy answers instead of BBB", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(`<html><body>502 Bad Gateway</body></html>`))
+			}))
+			defer server.Close()
+
+			_, err := services.IsMeetingRunning(client, server.URL)
+			Expect(err).To(MatchError(ContainSubstring("502")))
+		})
+
+		It("should return an error when the server is unreachable", func() {
+			server := serve("")
+			server.Close()
+
+			_, err := services.IsMeetingRunning(client, server.URL)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("monitorMeeting", func() {
+		var (
+			session *services.Session
+			page    *fakePage
+		)
+
+		BeforeEach(func() {
+			DeferCleanup(services.ShortenMonitorTimings(10*time.Millisecond, 200*time.Millisecond))
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<response><returncode>SUCCESS</returncode><running>true</running></response>`))
+			}))
+			DeferCleanup(server.Close)
+			session = services.NewSession(&models.BroadcasterRequest{BBBServerURL: "https://example.com/join", BBBHealthCheckURL: server.URL})
+			page = &fakePage{}
+		})
+
+		monitor := func() chan error {
+			done := make(chan error, 1)
+			go func() {
+				done <- services.MonitorMeeting(page, session)
+			}()
+			return done
+		}
+
+		eventTypes := func() []string {
+			var types []string
+			for _, event := range session.Snapshot().Events {
+				types = append(types, event.Type)
+			}
+			return types
+		}
+
+		connectionLost := map[string]string{`[data-test="notificationBannerBar"]`: "Connection lost, trying to reconnect..."}
+
+		It("should not wait for the client to recover when the chat says it is reconnecting", func() {
+			page.show(map[string]string{
+				`[data-test="chatMessageText"]`: "my audio keeps reconnecting",
+				"body":                          "Public chat\nmy audio keeps reconnecting",
+			})
+			done := monitor()
+			Consistently(eventTypes, 300*time.Millisecond).ShouldNot(ContainElement("reconnecting"))
+			Expect(page.Navigations()).To(Equal(0))
+
+			page.show(map[string]string{`[data-test="meetingEndedModalTitle"]`: "This session has ended"})
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should keep the page when the client recovers before the deadline", func() {
+			page.show(connectionLost)
+			done := monitor()
+			Eventually(eventTypes).Should(ContainElement("reconnecting"))
+
+			page.show(nil)
+			Eventually(eventTypes).Should(ContainElement("recovered"))
+			Consistently(page.Navigations, 300*time.Millisecond).Should(Equal(0))
+
+			page.show(map[string]string{`[data-test="meetingEndedModalTitle"]`: "This session has ended"})
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should rejoin when the client does not recover and fail once the rejoins run out", func() {
+			page.show(connectionLost)
+
+			var err error
+			Eventually(monitor(), 5*time.Second).Should(Receive(&err))
+			var sessionErr *services.SessionError
+			Expect(errors.As(err, &sessionErr)).To(BeTrue())
+			Expect(sessionErr.Reason).To(Equal(models.ReasonConnectionLost))
+			Expect(page.Navigations()).To(Equal(3))
+			Expect(eventTypes()).To(Equal([]string{"reconnecting", "rejoin", "reconnecting", "rejoin", "reconnecting", "rejoin", "reconnecting"}))
+		})
+
+		It("should not end the broadcast when the chat says the session has ended", func() {
+			page.show(map[string]string{
+				`[data-test="chatMessageText"]`: "This session has ended, you have been removed",
+				"body":                          "Public chat\nThis session has ended, you have been removed",
+			})
+			done := monitor()
+			Consistently(done, 300*time.Millisecond).ShouldNot(Receive())
+
+			page.show(map[string]string{`[data-test="meetingEndedModalTitle"]`: "This session has ended"})
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(session.State()).To(Equal(models.SessionStateEnded))
+		})
+
+		It("should fail with removed_from_meeting when BBB shows the bot was removed", func() {
+			page.show(map[string]string{`[data-test="meetingEndedModalTitle"]`: "You have been removed from the meeting"})
+
+			var err error
+			Eventually(monitor()).Should(Receive(&err))
+			var sessionErr *services.SessionError
+			Expect(errors.As(err, &sessionErr)).To(BeTrue())
+			Expect(sessionErr.Reason).To(Equal(models.ReasonRemovedFromMeeting))
+		})
+	})
+})
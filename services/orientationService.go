@@ -0,0 +1,155 @@
+package services
+
+import (
+	"log"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+// portraitCSS stacks the BBB client for 9:16 viewing: webcams across the top
+// and the presentation or screenshare below, filling its area and cropped at
+// the sides rather than letterboxed.
+const portraitCSS = `
+html.spoutbreeze-portrait #cameraDock,
+html.spoutbreeze-portrait [data-test="webcamsContainer"] {
+	position: fixed !important;
+	top: 0 !important;
+	left: 0 !important;
+	width: 100vw !important;
+	height: 38vh !important;
+	z-index: 1001 !important;
+}
+html.spoutbreeze-portrait [data-test="presentationContainer"],
+html.spoutbreeze-portrait section[aria-label="Presentation"],
+html.spoutbreeze-portrait [data-test="screenshareContainer"] {
+	position: fixed !important;
+	top: 38vh !important;
+	left: 0 !important;
+	width: 100vw !important;
+	height: 62vh !important;
+	overflow: hidden !important;
+	z-index: 1000 !important;
+	background: #000 !important;
+}
+html.spoutbreeze-portrait [data-test="presentationContainer"] svg,
+html.spoutbreeze-portrait section[aria-label="Presentation"] svg {
+	height: 100% !important;
+	width: auto !important;
+	max-width: none !important;
+	position: relative !important;
+	left: 50% !important;
+	transform: translateX(-50%) !important;
+}
+html.spoutbreeze-portrait video[data-test="screenShareVideo"],
+html.spoutbreeze-portrait video#screenshareVideo {
+	width: 100% !important;
+	height: 100% !important;
+	object-fit: cover !important;
+}
+`
+
+// portraitLayoutScript installs portraitCSS and marks the document for it.
+// Like cleanFeedScript it is idempotent and survives head re-renders.
+const portraitLayoutScript = `
+var css = arguments[0];
+var install = function () {
+	if (!document.getElementById("spoutbreeze-portrait")) {
+		var style = document.createElement("style");
+		style.id = "spoutbreeze-portrait";
+		style.textContent = css;
+		document.head.appendChild(style);
+	}
+	document.documentElement.classList.add("spoutbreeze-portrait");
+};
+install();
+if (!window.__spoutbreezePortraitObserver) {
+	window.__spoutbreezePortraitObserver = new MutationObserver(install);
+	window.__spoutbreezePortraitObserver.observe(document.head, { childList: true });
+}
+`
+
+func orientationOf(orientation string) string {
+	if orientation == "" {
+		return models.OrientationLandscape
+	}
+	return orientation
+}
+
+func applyPortraitLayout(driver selenium.WebDriver) {
+	_, err := driver.ExecuteScript(portraitLayoutScript, []interface{}{portraitCSS})
+	if err != nil {
+		log.Printf("Warning: Failed to apply portrait layout: %v", err)
+	}
+}
+
+// splitByOrientation gives each orientation its own request, as one bot can
+// only produce one picture. The destinations must already be resolved, the
+// copies do not fall back to rtmp_url. Landscape comes first.
+func splitByOrientation(request *models.BroadcasterRequest) []*models.BroadcasterRequest {
+	var requests []*models.BroadcasterRequest
+	for _, orientation := range []string{models.OrientationLandscape, models.OrientationPortrait} {
+		group := *request
+		group.RTMPURL = ""
+		group.StreamKey = ""
+		group.Platform = ""
+		group.SRT = nil
+		group.Orientation = orientation
+		group.Destinations = nil
+		group.WHIPDestinations = nil
+		for _, destination := range request.Destinations {
+			if orientationOf(destination.Orientation) == orientation {
+				group.Destinations = append(group.Destinations, destination)
+			}
+		}
+		for _, destination := range request.WHIPDestinations {
+			if orientationOf(destination.Orientation) == orientation {
+				group.WHIPDestinations = append(group.WHIPDestinations, destination)
+			}
+		}
+		if len(group.Destinations) > 0 || len(group.WHIPDestinations) > 0 {
+			requests = append(requests, &group)
+		}
+	}
+	if len(requests) == 0 {
+		// Nothing to stream to, keep the request as it is
+		return []*models.BroadcasterRequest{request}
+	}
+	return requests
+}
+
+// newSessionGroup registers a session per orientation of the request. The
+// first is the one reported to the caller, the others are its companions.
+func newSessionGroup(request *models.BroadcasterRequest, sessionType models.SessionType) []*Session {
+	var group []*Session
+	for _, orientationRequest := range splitByOrientation(request) {
+		group = append(group, newSession(orientationRequest, sessionType))
+	}
+
+	primary := group[0]
+	for _, companion := range group[1:] {
+		companion.setParent(primary.ID)
+		primary.addCompanion(companion.ID)
+	}
+	return group
+}
+
+func (s *Session) setParent(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parentID = sessionID
+}
+
+func (s *Session) addCompanion(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.companions = append(s.companions, sessionID)
+}
+
+// Companions returns the IDs of the sessions started together with this one
+// for destinations of another orientation.
+func (s *Session) Companions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.companions...)
+}
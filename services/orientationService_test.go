@@ -0,0 +1,55 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Orientation Service", func() {
+	It("should register a companion session for portrait destinations", func() {
+		request := &models.BroadcasterRequest{
+			Destinations: []models.Destination{
+				{URL: "rtmp://a.rtmp.youtube.com/live2", Key: "abcd-efgh-ijkl-mnop", Label: "youtube"},
+				{URL: "rtmp://push.tiktokcdn.com/game/", Key: "stream-123", Label: "tiktok", Orientation: models.OrientationPortrait},
+			},
+		}
+		Expect(services.PrepareDestinations(request)).To(Succeed())
+
+		group := services.NewSessionGroup(request, models.SessionTypeBroadcast)
+		Expect(group).To(HaveLen(2))
+		session := group[0]
+
+		snapshot := session.Snapshot()
+		Expect(snapshot.Destinations).To(HaveLen(1))
+		Expect(snapshot.Destinations[0].Label).To(Equal("youtube"))
+		Expect(snapshot.Destinations[0].Orientation).To(Equal(models.OrientationLandscape))
+		Expect(snapshot.CompanionSessionIDs).To(HaveLen(1))
+
+		companion, ok := services.GetSession(snapshot.CompanionSessionIDs[0])
+		Expect(ok).To(BeTrue())
+		Expect(companion.Request.Orientation).To(Equal(models.OrientationPortrait))
+		companionSnapshot := companion.Snapshot()
+		Expect(companionSnapshot.ParentSessionID).To(Equal(session.ID))
+		Expect(companionSnapshot.Destinations).To(HaveLen(1))
+		Expect(companionSnapshot.Destinations[0].Label).To(Equal("tiktok"))
+		Expect(companionSnapshot.Destinations[0].Platform).To(Equal(models.PlatformTikTok))
+	})
+
+	It("should use a single portrait bot when every destination is portrait", func() {
+		request := &models.BroadcasterRequest{
+			RTMPURL:     "rtmp://push.tiktokcdn.com/game/",
+			StreamKey:   "stream-123",
+			Orientation: models.OrientationPortrait,
+		}
+		Expect(services.PrepareDestinations(request)).To(Succeed())
+
+		group := services.NewSessionGroup(request, models.SessionTypeBroadcast)
+		Expect(group).To(HaveLen(1))
+		session := group[0]
+		Expect(session.Request.Orientation).To(Equal(models.OrientationPortrait))
+		Expect(session.Companions()).To(BeEmpty())
+	})
+})
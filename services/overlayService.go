@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/initializers"
+	"spoutbreeze/models"
+	"spoutbreeze/repositories"
+)
+
+var ErrOverlayNotFound = errors.New("overlay not found")
+
+// overlayScript renders the overlays into a fixed layer above the BBB client.
+// Each overlay element keeps a signature of its definition, so reapplying an
+// unchanged list does not touch the DOM and the stream does not flicker.
+const overlayScript = `
+var overlays = arguments[0] || [];
+var root = document.getElementById("spoutbreeze-overlays");
+if (!root) {
+	root = document.createElement("div");
+	root.id = "spoutbreeze-overlays";
+	root.style.cssText = "position:fixed;inset:0;pointer-events:none;z-index:2147483646;";
+	document.body.appendChild(root);
+}
+var positions = {
+	"top-left": "top:24px;left:24px;",
+	"top-right": "top:24px;right:24px;",
+	"bottom-left": "bottom:32px;left:24px;",
+	"bottom-right": "bottom:32px;right:24px;"
+};
+var defaultPositions = { logo: "top-right", lower_third: "bottom-left", live_badge: "top-left" };
+var seen = {};
+overlays.forEach(function (overlay) {
+	var id = "spoutbreeze-overlay-" + overlay.id;
+	var signature = JSON.stringify(overlay);
+	seen[id] = true;
+	var el = document.getElementById(id);
+	if (el && el.getAttribute("data-signature") === signature) {
+		return;
+	}
+	if (!el) {
+		el = document.createElement("div");
+		el.id = id;
+		root.appendChild(el);
+	}
+	el.setAttribute("data-signature", signature);
+	el.innerHTML = "";
+	var css = "position:absolute;" + positions[overlay.position || defaultPositions[overlay.type]];
+	if (overlay.type === "logo") {
+		var img = document.createElement("img");
+		img.src = overlay.image_url;
+		img.style.cssText = "display:block;max-height:80px;max-width:240px;";
+		el.appendChild(img);
+	} else if (overlay.type === "lower_third") {
+		css += "padding:12px 24px;background:rgba(0,0,0,0.75);color:#fff;font:600 28px sans-serif;border-left:6px solid #e53935;";
+		el.textContent = overlay.text || "";
+	} else if (overlay.type === "live_badge") {
+		css += "padding:6px 14px;background:#e53935;color:#fff;font:700 22px sans-serif;letter-spacing:2px;border-radius:4px;";
+		el.textContent = overlay.text || "LIVE";
+	}
+	if (overlay.hidden) {
+		css += "display:none;";
+	}
+	el.style.cssText = css;
+	var style = overlay.style || {};
+	Object.keys(style).forEach(function (property) {
+		el.style.setProperty(property, style[property]);
+	});
+});
+Array.prototype.slice.call(root.children).forEach(function (child) {
+	if (!seen[child.id]) {
+		root.removeChild(child);
+	}
+});
+`
+
+func tenantOverlays(tenantID string) ([]models.Overlay, error) {
+	if initializers.RedisClient == nil {
+		return nil, fmt.Errorf("tenant profile %s requested but Redis is not configured", tenantID)
+	}
+	profile, err := repositories.GetTenantProfile(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant profile %s: %w", tenantID, err)
+	}
+	return profile.Overlays, nil
+}
+
+func applyOverlays(driver selenium.WebDriver, overlays []models.Overlay) {
+	if len(overlays) == 0 {
+		return
+	}
+	_, err := driver.ExecuteScript(overlayScript, []interface{}{overlays})
+	if err != nil {
+		log.Printf("Warning: Failed to apply overlays: %v", err)
+	}
+}
+
+// Overlays returns a copy of the session's current overlay definitions.
+func (s *Session) Overlays() []models.Overlay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Overlay{}, s.overlays...)
+}
+
+func (s *Session) updateOverlay(overlayID string, update models.OverlayUpdate) (models.Overlay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.overlays {
+		overlay := &s.overlays[i]
+		if overlay.ID != overlayID {
+			continue
+		}
+		if update.Text != nil {
+			overlay.Text = *update.Text
+		}
+		if update.ImageURL != nil {
+			overlay.ImageURL = *update.ImageURL
+		}
+		if update.Hidden != nil {
+			overlay.Hidden = *update.Hidden
+		}
+		return *overlay, nil
+	}
+	return models.Overlay{}, ErrOverlayNotFound
+}
+
+// UpdateSessionOverlay changes an overlay of a session, such as the
+// lower-third text, and redraws it right away when the bot is live.
+func UpdateSessionOverlay(sessionID string, overlayID string, update models.OverlayUpdate) (models.Overlay, error) {
+	session, ok := GetSession(sessionID)
+	if !ok {
+		return models.Overlay{}, ErrSessionNotFound
+	}
+
+	overlay, err := session.updateOverlay(overlayID, update)
+	if err != nil {
+		return models.Overlay{}, err
+	}
+	session.AddEvent("overlay_updated", "overlay "+overlayID+" updated")
+
+	if driver := session.Driver(); driver != nil {
+		applyOverlays(driver, session.Overlays())
+	}
+	return overlay, nil
+}
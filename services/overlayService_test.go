@@ -0,0 +1,52 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Overlay Service", func() {
+	var session *services.Session
+
+	BeforeEach(func() {
+		session = services.NewSession(&models.BroadcasterRequest{
+			Overlays: []models.Overlay{
+				{ID: "logo", Type: models.OverlayTypeLogo, ImageURL: "https://example.com/logo.png"},
+				{ID: "title", Type: models.OverlayTypeLowerThird, Text: "Lecture 1"},
+			},
+		})
+	})
+
+	Describe("UpdateSessionOverlay", func() {
+		It("should update the lower-third text of a session", func() {
+			text := "Lecture 2: Graphs"
+
+			overlay, err := services.UpdateSessionOverlay(session.ID, "title", models.OverlayUpdate{Text: &text})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(overlay.Text).To(Equal(text))
+			Expect(session.Overlays()[1].Text).To(Equal(text))
+			Expect(session.Overlays()[0].ImageURL).To(Equal("https://example.com/logo.png"))
+		})
+
+		It("should hide an overlay", func() {
+			hidden := true
+
+			overlay, err := services.UpdateSessionOverlay(session.ID, "logo", models.OverlayUpdate{Hidden: &hidden})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(overlay.Hidden).To(BeTrue())
+		})
+
+		It("should return ErrOverlayNotFound for unknown overlays", func() {
+			_, err := services.UpdateSessionOverlay(session.ID, "unknown", models.OverlayUpdate{})
+			Expect(err).To(MatchError(services.ErrOverlayNotFound))
+		})
+
+		It("should return ErrSessionNotFound for unknown sessions", func() {
+			_, err := services.UpdateSessionOverlay("unknown", "title", models.OverlayUpdate{})
+			Expect(err).To(MatchError(services.ErrSessionNotFound))
+		})
+	})
+})
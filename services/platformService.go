@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"spoutbreeze/models"
+)
+
+// PlatformPreset describes a streaming platform's ingest servers and what
+// its stream keys look like.
+type PlatformPreset struct {
+	PrimaryURL string
+	BackupURL  string
+	// Ingest host suffixes used to recognise the platform from a URL
+	Hosts      []string
+	KeyPattern *regexp.Regexp
+	KeyExample string
+	// Limits of what the platform ingests, zero means no limit
+	MaxFrameRate        int
+	MaxVideoBitrateKbps int
+	// Whether the platform takes streams without video
+	AudioOnly bool
+}
+
+// LinkedIn and TikTok hand out a new ingest URL for every live event and
+// custom destinations bring their own, so none of them has default URLs.
+// The video platforms do not take audio-only streams.
+var platformPresets = map[string]PlatformPreset{
+	models.PlatformYouTube: {
+		PrimaryURL: "rtmp://a.rtmp.youtube.com/live2",
+		BackupURL:  "rtmp://b.rtmp.youtube.com/live2?backup=1",
+		Hosts:      []string{"youtube.com"},
+		KeyPattern: regexp.MustCompile(`^[a-z0-9]{4}(-[a-z0-9]{4}){3,4}$`),
+		KeyExample: "abcd-efgh-ijkl-mnop",
+	},
+	models.PlatformTwitch: {
+		PrimaryURL: "rtmp://live.twitch.tv/app",
+		Hosts:      []string{"twitch.tv"},
+		KeyPattern: regexp.MustCompile(`^live_\d+_[A-Za-z0-9]+$`),
+		KeyExample: "live_123456789_AbCdEf",
+		// Twitch caps ingest bitrate for non-partner channels
+		MaxVideoBitrateKbps: 6000,
+	},
+	models.PlatformFacebook: {
+		PrimaryURL: "rtmps://live-api-s.facebook.com:443/rtmp",
+		Hosts:      []string{"facebook.com"},
+		KeyPattern: regexp.MustCompile(`^(FB-\d+-\d+-[A-Za-z0-9_-]+|\d+\?s_.+)$`),
+		KeyExample: "FB-1234567890-0-AbCdEf",
+	},
+	models.PlatformKick: {
+		PrimaryURL: "rtmps://fa723fc1b171.global-contribute.live-video.net:443/app",
+		Hosts:      []string{"fa723fc1b171.global-contribute.live-video.net"},
+		KeyPattern: regexp.MustCompile(`^sk_[a-z0-9-]+_[A-Za-z0-9]+$`),
+		KeyExample: "sk_us-west-2_AbCdEf",
+	},
+	models.PlatformLinkedIn: {MaxFrameRate: 30},
+	// TikTok gives out a server URL per stream, like LinkedIn
+	models.PlatformTikTok: {Hosts: []string{"tiktokcdn.com"}},
+	models.PlatformCustom: {AudioOnly: true},
+}
+
+// detectPlatform recognises a platform from its ingest URL host.
+func detectPlatform(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := parsed.Hostname()
+	for platform, preset := range platformPresets {
+		for _, suffix := range preset.Hosts {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return platform
+			}
+		}
+	}
+	return ""
+}
+
+// ResolveDestination fills in the ingest URL of a destination from its
+// platform preset and checks that the stream key matches the platform. When
+// no platform is given it is recognised from the URL, so that a key pasted
+// for the wrong platform is caught. Destinations on unknown hosts are taken
+// as they are.
+func ResolveDestination(destination models.Destination) (models.Destination, error) {
+	platform := destination.Platform
+	detected := detectPlatform(destination.URL)
+	if platform == "" {
+		platform = detected
+	}
+	if platform == "" {
+		return destination, nil
+	}
+
+	preset, ok := platformPresets[platform]
+	if !ok {
+		return destination, fmt.Errorf("%w: unknown platform %q", ErrInvalidRequest, platform)
+	}
+	if detected != "" && detected != platform {
+		return destination, fmt.Errorf("%w: destination %q is a %s ingest URL but the platform is %s", ErrInvalidRequest, destination.Label, detected, platform)
+	}
+
+	if destination.URL == "" {
+		ingestURL := preset.PrimaryURL
+		if destination.Backup {
+			ingestURL = preset.BackupURL
+		}
+		if ingestURL == "" {
+			return destination, fmt.Errorf("%w: destination %q needs a url, %s has no default ingest for it", ErrInvalidRequest, destination.Label, platform)
+		}
+		destination.URL = ingestURL
+	}
+
+	if preset.KeyPattern != nil && !preset.KeyPattern.MatchString(destination.Key) {
+		return destination, fmt.Errorf("%w: stream key of destination %q does not look like a %s key (expected something like %s)", ErrInvalidRequest, destination.Label, platform, preset.KeyExample)
+	}
+
+	destination.Platform = platform
+	return destination, nil
+}
+
+func resolveDestinations(destinations []models.Destination) ([]models.Destination, error) {
+	resolved := make([]models.Destination, 0, len(destinations))
+	for _, destination := range destinations {
+		destination, err := ResolveDestination(destination)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, destination)
+	}
+	return resolved, nil
+}
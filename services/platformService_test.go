@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Platform Service", func() {
+	DescribeTable("ResolveDestination with valid destinations",
+		func(destination models.Destination, expectedURL string, expectedPlatform string) {
+			resolved, err := services.ResolveDestination(destination)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolved.URL).To(Equal(expectedURL))
+			Expect(resolved.Platform).To(Equal(expectedPlatform))
+		},
+		Entry("YouTube primary ingest",
+			models.Destination{Label: "yt", Platform: models.PlatformYouTube, Key: "abcd-efgh-ijkl-mnop"},
+			"rtmp://a.rtmp.youtube.com/live2", models.PlatformYouTube),
+		Entry("YouTube backup ingest",
+			models.Destination{Label: "yt", Platform: models.PlatformYouTube, Key: "abcd-efgh-ijkl-mnop-qrst", Backup: true},
+			"rtmp://b.rtmp.youtube.com/live2?backup=1", models.PlatformYouTube),
+		Entry("Twitch recognised from its URL",
+			models.Destination{Label: "twitch", URL: "rtmp://live.twitch.tv/app", Key: "live_123456789_AbCdEf"},
+			"rtmp://live.twitch.tv/app", models.PlatformTwitch),
+		Entry("Facebook over RTMPS",
+			models.Destination{Label: "fb", Platform: models.PlatformFacebook, Key: "FB-1234567890-0-AbCdEf"},
+			"rtmps://live-api-s.facebook.com:443/rtmp", models.PlatformFacebook),
+		Entry("Facebook persistent key",
+			models.Destination{Label: "fb", URL: "rtmps://live-api-s.facebook.com:443/rtmp", Key: "1234567890?s_bl=1&s_sw=0&a=AbCdEf"},
+			"rtmps://live-api-s.facebook.com:443/rtmp", models.PlatformFacebook),
+		Entry("Kick",
+			models.Destination{Label: "kick", Platform: models.PlatformKick, Key: "sk_us-west-2_AbCdEf"},
+			"rtmps://fa723fc1b171.global-contribute.live-video.net:443/app", models.PlatformKick),
+		Entry("custom server taken as is",
+			models.Destination{Label: "cdn", URL: "rtmp://streaming.example.com/live", Key: "anything"},
+			"rtmp://streaming.example.com/live", ""),
+	)
+
+	DescribeTable("ResolveDestination with invalid destinations",
+		func(destination models.Destination) {
+			_, err := services.ResolveDestination(destination)
+			Expect(err).To(MatchError(services.ErrInvalidRequest))
+		},
+		Entry("YouTube key on a Twitch URL",
+			models.Destination{Label: "twitch", URL: "rtmp://live.twitch.tv/app", Key: "abcd-efgh-ijkl-mnop"}),
+		Entry("Twitch URL declared as YouTube",
+			models.Destination{Label: "yt", URL: "rtmp://live.twitch.tv/app", Platform: models.PlatformYouTube, Key: "abcd-efgh-ijkl-mnop"}),
+		Entry("LinkedIn without an event ingest URL",
+			models.Destination{Label: "li", Platform: models.PlatformLinkedIn, Key: "key"}),
+		Entry("Twitch key without its channel part",
+			models.Destination{Label: "twitch", URL: "rtmp://live.twitch.tv/app", Key: "live_123"}),
+		Entry("YouTube key too short",
+			models.Destination{Label: "yt", Platform: models.PlatformYouTube, Key: "yt-key"}),
+		Entry("Kick key without its region",
+			models.Destination{Label: "kick", Platform: models.PlatformKick, Key: "sk_AbCdEf"}),
+		Entry("Twitch backup ingest",
+			models.Destination{Label: "twitch", Platform: models.PlatformTwitch, Key: "live_123456789_AbCdEf", Backup: true}),
+	)
+})
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"spoutbreeze/models"
+)
+
+const preflightTimeout = 5 * time.Second
+
+const rtmpHandshakeSize = 1536
+
+// preflightStep runs one or more related checks, such as the checksum and
+// meeting checks that share a join probe.
+type preflightStep func() []models.PreflightCheck
+
+// ValidateBroadcasterRequest runs the checks of a real start without launching
+// a browser and reports the outcome of each. Checks run concurrently and each
+// is bounded by preflightTimeout.
+func ValidateBroadcasterRequest(request *models.BroadcasterRequest) models.PreflightReport {
+	client := &http.Client{Timeout: preflightTimeout}
+
+	// A real start resolves the destinations into the request, the caller's
+	// request is left as it was sent
+	prepared := *request
+	err := prepareDestinations(&prepared)
+	destinations := prepared.Destinations
+	if err != nil {
+		destinations = requestDestinations(request)
+	}
+
+	steps := []preflightStep{
+		func() []models.PreflightCheck {
+			if err != nil {
+				return failedCheck("request", err.Error())
+			}
+			return passedCheck("request", "request is valid")
+		},
+	}
+	if request.GreenlightRoomURL != "" {
+		steps = append(steps,
+			func() []models.PreflightCheck { return checkGreenlightRoom(client, request.GreenlightRoomURL) },
+		)
+	} else {
+		steps = append(steps,
+			func() []models.PreflightCheck { return checkBBBAPI(client, request.BBBServerURL) },
+			func() []models.PreflightCheck { return checkBBBJoin(request.BBBServerURL) },
+		)
+	}
+	steps = append(steps, func() []models.PreflightCheck { return checkHealthURL(client, request.BBBHealthCheckURL) })
+	for _, destination := range destinations {
+		destination := destination
+		steps = append(steps, func() []models.PreflightCheck { return checkDestination(destination) })
+	}
+	for _, destination := range request.WHIPDestinations {
+		destination := destination
+		steps = append(steps, func() []models.PreflightCheck { return checkWHIPEndpoint(destination) })
+	}
+	steps = append(steps, func() []models.PreflightCheck { return checkMoonHub(client, seleniumHubURL()) })
+
+	results := make([][]models.PreflightCheck, len(steps))
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(i int, step preflightStep) {
+			defer wg.Done()
+			started := time.Now()
+			checks := step()
+			for j := range checks {
+				checks[j].DurationMs = time.Since(started).Milliseconds()
+			}
+			results[i] = checks
+		}(i, step)
+	}
+	wg.Wait()
+
+	report := models.PreflightReport{OK: true, Checks: []models.PreflightCheck{}}
+	for _, checks := range results {
+		for _, check := range checks {
+			if check.Status == models.PreflightFailed {
+				report.OK = false
+			}
+			report.Checks = append(report.Checks, check)
+		}
+	}
+	return report
+}
+
+func preflightCheck(name string, status string, message string) []models.PreflightCheck {
+	return []models.PreflightCheck{{Name: name, Status: status, Message: message}}
+}
+
+func passedCheck(name string, message string) []models.PreflightCheck {
+	return preflightCheck(name, models.PreflightPassed, message)
+}
+
+func failedCheck(name string, message string) []models.PreflightCheck {
+	return preflightCheck(name, models.PreflightFailed, message)
+}
+
+// bbbAPIResponse covers the fields of BBB API responses the checks use.
+type bbbAPIResponse struct {
+	ReturnCode string `xml:"returncode"`
+	MessageKey string `xml:"messageKey"`
+	Message    string `xml:"message"`
+	Version    string `xml:"version"`
+}
+
+// checkBBBAPI calls the API root of the server in the join link, which
+// answers with its version.
+func checkBBBAPI(client *http.Client, joinURL string) []models.PreflightCheck {
+	index := strings.Index(joinURL, "/api/")
+	if index == -1 {
+		return failedCheck("bbb_api", "bbb_server_url is not a BBB API join link")
+	}
+	apiURL := joinURL[:index+len("/api")]
+
+	resp, err := client.Get(apiURL)
+	if err != nil {
+		return failedCheck("bbb_api", fmt.Sprintf("BBB server is not reachable: %v", err))
+	}
+	defer resp.Body.Close()
+
+	var response bbbAPIResponse
+	body, _ := io.ReadAll(resp.Body)
+	if err := xml.Unmarshal(body, &response); err != nil || response.ReturnCode != "SUCCESS" {
+		return failedCheck("bbb_api", fmt.Sprintf("%s did not answer like a BBB API (%s)", apiURL, resp.Status))
+	}
+	return passedCheck("bbb_api", "BBB API version "+response.Version)
+}
+
+// checkBBBJoin requests the join link without following its redirect into
+// the client. BBB redirects when the checksum is valid and the meeting
+// exists, and answers with an error document otherwise. BBB registers the
+// user on this request, so the check shows up in the meeting like a join
+// that was never completed, and waits in the lobby of guest-policy meetings.
+func checkBBBJoin(joinURL string) []models.PreflightCheck {
+	client := &http.Client{
+		Timeout: preflightTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(joinURL)
+	if err != nil {
+		return append(failedCheck("checksum", fmt.Sprintf("join link could not be checked: %v", err)),
+			preflightCheck("meeting", models.PreflightSkipped, "join link could not be checked")...)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return append(passedCheck("checksum", "join link checksum is valid"),
+			passedCheck("meeting", "meeting exists")...)
+	}
+
+	var response bbbAPIResponse
+	body, _ := io.ReadAll(resp.Body)
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return append(failedCheck("checksum", fmt.Sprintf("unexpected join response (%s)", resp.Status)),
+			preflightCheck("meeting", models.PreflightSkipped, "join link could not be checked")...)
+	}
+	switch {
+	case response.ReturnCode == "SUCCESS":
+		return append(passedCheck("checksum", "join link checksum is valid"),
+			passedCheck("meeting", "meeting exists")...)
+	case response.MessageKey == "checksumError":
+		return append(failedCheck("checksum", response.Message),
+			preflightCheck("meeting", models.PreflightSkipped, "checksum is invalid")...)
+	default:
+		return append(passedCheck("checksum", "join link checksum is valid"),
+			failedCheck("meeting", response.MessageKey+": "+response.Message)...)
+	}
+}
+
+func checkHealthURL(client *http.Client, healthCheckURL string) []models.PreflightCheck {
+	running, err := IsMeetingRunning(client, healthCheckURL)
+	if err != nil {
+		return failedCheck("health_check", err.Error())
+	}
+	if !running {
+		return preflightCheck("health_check", models.PreflightWarning, "meeting is not running yet")
+	}
+	return passedCheck("health_check", "meeting is running")
+}
+
+func checkGreenlightRoom(client *http.Client, roomURL string) []models.PreflightCheck {
+	resp, err := client.Get(roomURL)
+	if err != nil {
+		return failedCheck("greenlight_room", fmt.Sprintf("Greenlight room is not reachable: %v", err))
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return failedCheck("greenlight_room", "Greenlight room answered "+resp.Status)
+	}
+	return passedCheck("greenlight_room", "Greenlight room is reachable")
+}
+
+// checkDestination resolves the ingest host and, for RTMP and RTMPS, runs
+// the first part of the RTMP handshake. SRT is UDP without a cheap probe, so
+// only its host is resolved. Icecast mounts are checked for a listening
+// server.
+func checkDestination(destination models.Destination) []models.PreflightCheck {
+	name := "destination:" + destination.Label
+	parsed, err := url.Parse(destination.URL)
+	if err != nil || parsed.Hostname() == "" {
+		return failedCheck(name, "invalid ingest URL")
+	}
+	if _, err := net.LookupHost(parsed.Hostname()); err != nil {
+		return failedCheck(name, fmt.Sprintf("ingest host does not resolve: %v", err))
+	}
+
+	protocol := DestinationProtocol(destination)
+	if protocol == models.ProtocolSRT {
+		return passedCheck(name, "ingest host resolves, SRT handshake not checked")
+	}
+	if isIcecast(destination) {
+		return checkIcecastServer(name, parsed)
+	}
+
+	port := parsed.Port()
+	if port == "" {
+		port = "1935"
+		if protocol == models.ProtocolRTMPS {
+			port = "443"
+		}
+	}
+	err = rtmpHandshake(net.JoinHostPort(parsed.Hostname(), port), protocol == models.ProtocolRTMPS)
+	if err != nil {
+		return failedCheck(name, err.Error())
+	}
+	return passedCheck(name, "ingest accepted the RTMP handshake")
+}
+
+func checkIcecastServer(name string, parsed *url.URL) []models.PreflightCheck {
+	port := parsed.Port()
+	if port == "" {
+		switch parsed.Scheme {
+		case models.ProtocolHTTP:
+			port = "80"
+		case models.ProtocolHTTPS:
+			port = "443"
+		default:
+			port = "8000"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(parsed.Hostname(), port), preflightTimeout)
+	if err != nil {
+		return failedCheck(name, fmt.Sprintf("Icecast server is not reachable: %v", err))
+	}
+	conn.Close()
+	return passedCheck(name, "Icecast server is reachable")
+}
+
+// rtmpHandshake sends C0 and C1 and waits for S0 and S1, which is enough to
+// know an RTMP server is listening. C2 is sent back before closing.
+func rtmpHandshake(address string, useTLS bool) error {
+	dialer := &net.Dialer{Timeout: preflightTimeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(preflightTimeout))
+
+	c1 := make([]byte, rtmpHandshakeSize)
+	rand.Read(c1[8:])
+	if _, err := conn.Write(append([]byte{3}, c1...)); err != nil {
+		return fmt.Errorf("failed to send RTMP handshake: %w", err)
+	}
+
+	s0s1 := make([]byte, 1+rtmpHandshakeSize)
+	if _, err := io.ReadFull(conn, s0s1); err != nil {
+		return fmt.Errorf("no RTMP handshake from %s: %w", address, err)
+	}
+	if s0s1[0] != 3 {
+		return fmt.Errorf("%s answered with RTMP version %d", address, s0s1[0])
+	}
+	conn.Write(s0s1[1:])
+	return nil
+}
+
+func checkWHIPEndpoint(destination models.WHIPDestination) []models.PreflightCheck {
+	name := "destination:" + destination.Label
+	parsed, err := url.Parse(destination.EndpointURL)
+	if err != nil || parsed.Hostname() == "" {
+		return failedCheck(name, "invalid WHIP endpoint URL")
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "443"
+		if parsed.Scheme == "http" {
+			port = "80"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(parsed.Hostname(), port), preflightTimeout)
+	if err != nil {
+		return failedCheck(name, fmt.Sprintf("WHIP endpoint is not reachable: %v", err))
+	}
+	conn.Close()
+	return passedCheck(name, "WHIP endpoint is reachable")
+}
+
+// checkMoonHub asks the Moon hub whether it accepts new sessions.
+func checkMoonHub(client *http.Client, hubURL string) []models.PreflightCheck {
+	resp, err := client.Get(hubURL + "/status")
+	if err != nil {
+		return failedCheck("moon_hub", fmt.Sprintf("Moon hub is not reachable: %v", err))
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Value struct {
+			Ready   bool   `json:"ready"`
+			Message string `json:"message"`
+		} `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return failedCheck("moon_hub", fmt.Sprintf("unexpected Moon hub status (%s)", resp.Status))
+	}
+	if !status.Value.Ready {
+		return failedCheck("moon_hub", "Moon hub does not accept sessions: "+status.Value.Message)
+	}
+	return passedCheck("moon_hub", "Moon hub accepts sessions")
+}
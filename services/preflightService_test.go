@@ -0,0 +1,142 @@
+package services_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// bbbStandIn answers the BBB API calls the preflight makes.
+func bbbStandIn() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bigbluebutton/api":
+			io.WriteString(w, `<response><returncode>SUCCESS</returncode><version>2.0</version></response>`)
+		case "/bigbluebutton/api/join":
+			switch r.URL.Query().Get("checksum") {
+			case "valid":
+				http.Redirect(w, r, "/html5client/join?sessionToken=abc", http.StatusFound)
+			case "unknown-meeting":
+				io.WriteString(w, `<response><returncode>FAILED</returncode><messageKey>invalidMeetingIdentifier</messageKey><message>The meeting ID that you supplied did not match any existing meetings</message></response>`)
+			default:
+				io.WriteString(w, `<response><returncode>FAILED</returncode><messageKey>checksumError</messageKey><message>Checksums do not match</message></response>`)
+			}
+		case "/bigbluebutton/api/isMeetingRunning":
+			io.WriteString(w, `<response><returncode>SUCCESS</returncode><running>true</running></response>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// rtmpStandIn accepts one RTMP handshake: it reads C0 and C1 and answers
+// with S0 and S1.
+func rtmpStandIn() net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c0c1 := make([]byte, 1537)
+		if _, err := io.ReadFull(conn, c0c1); err != nil {
+			return
+		}
+		conn.Write(append([]byte{3}, make([]byte, 1536)...))
+		io.ReadFull(conn, make([]byte, 1536))
+	}()
+	return listener
+}
+
+func findCheck(report models.PreflightReport, name string) models.PreflightCheck {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	Fail("no " + name + " check in the report")
+	return models.PreflightCheck{}
+}
+
+var _ = Describe("Preflight Service", func() {
+	var (
+		bbb  *httptest.Server
+		rtmp net.Listener
+	)
+
+	BeforeEach(func() {
+		bbb = bbbStandIn()
+		rtmp = rtmpStandIn()
+	})
+
+	AfterEach(func() {
+		bbb.Close()
+		rtmp.Close()
+	})
+
+	request := func(checksum string) *models.BroadcasterRequest {
+		return &models.BroadcasterRequest{
+			BBBServerURL:      bbb.URL + "/bigbluebutton/api/join?meetingID=m1&checksum=" + checksum,
+			BBBHealthCheckURL: bbb.URL + "/bigbluebutton/api/isMeetingRunning?meetingID=m1",
+			RTMPURL:           "rtmp://" + rtmp.Addr().String() + "/live",
+			StreamKey:         "stream-123",
+		}
+	}
+
+	It("should pass the BBB and destination checks of a working request", func() {
+		report := services.ValidateBroadcasterRequest(request("valid"))
+
+		Expect(findCheck(report, "request").Status).To(Equal(models.PreflightPassed))
+		Expect(findCheck(report, "bbb_api").Status).To(Equal(models.PreflightPassed))
+		Expect(findCheck(report, "checksum").Status).To(Equal(models.PreflightPassed))
+		Expect(findCheck(report, "meeting").Status).To(Equal(models.PreflightPassed))
+		Expect(findCheck(report, "health_check").Status).To(Equal(models.PreflightPassed))
+		Expect(findCheck(report, "destination:default").Status).To(Equal(models.PreflightPassed))
+		// No Moon hub is configured in tests
+		Expect(findCheck(report, "moon_hub").Status).To(Equal(models.PreflightFailed))
+		Expect(report.OK).To(BeFalse())
+	})
+
+	It("should report an invalid checksum", func() {
+		report := services.ValidateBroadcasterRequest(request("invalid"))
+
+		Expect(findCheck(report, "checksum").Status).To(Equal(models.PreflightFailed))
+		Expect(findCheck(report, "checksum").Message).To(ContainSubstring("Checksums do not match"))
+		Expect(findCheck(report, "meeting").Status).To(Equal(models.PreflightSkipped))
+	})
+
+	It("should report a meeting that does not exist", func() {
+		report := services.ValidateBroadcasterRequest(request("unknown-meeting"))
+
+		Expect(findCheck(report, "checksum").Status).To(Equal(models.PreflightPassed))
+		Expect(findCheck(report, "meeting").Status).To(Equal(models.PreflightFailed))
+	})
+
+	It("should report a destination that does not speak RTMP", func() {
+		req := request("valid")
+		req.RTMPURL = "rtmp://127.0.0.1:1/live"
+
+		report := services.ValidateBroadcasterRequest(req)
+
+		Expect(findCheck(report, "destination:default").Status).To(Equal(models.PreflightFailed))
+	})
+
+	It("should require the RTMP relay for a delay", func() {
+		req := request("valid")
+		req.DelaySeconds = 20
+
+		report := services.ValidateBroadcasterRequest(req)
+
+		Expect(findCheck(report, "request").Status).To(Equal(models.PreflightFailed))
+		Expect(findCheck(report, "request").Message).To(ContainSubstring("RTMP_RELAY_ADDR"))
+	})
+})
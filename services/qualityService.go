@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"spoutbreeze/models"
+)
+
+const defaultQuality = models.Quality1080p30
+
+// QualityProfile sets the size of the bot's browser and the encoder settings
+// of the capture container. Width and Height are the CSS viewport; the
+// captured picture is ScaleFactor times larger, so the 1080p profiles lay the
+// BBB client out like 720p with sharper text instead of shrinking it.
+type QualityProfile struct {
+	Name                string
+	Width               int
+	Height              int
+	ScaleFactor         float64
+	FrameRate           int
+	VideoBitrateKbps    int
+	KeyframeIntervalSec int
+	AudioCodec          string
+	AudioBitrateKbps    int
+	AudioSampleRate     int
+	AudioChannels       int
+	AudioOnly           bool
+}
+
+var qualityProfiles = map[string]QualityProfile{
+	models.Quality720p30: {
+		Name: models.Quality720p30, Width: 1280, Height: 720, ScaleFactor: 1, FrameRate: 30,
+		VideoBitrateKbps: 2500, KeyframeIntervalSec: 2, AudioBitrateKbps: 128, AudioSampleRate: 44100,
+	},
+	models.Quality1080p30: {
+		Name: models.Quality1080p30, Width: 1280, Height: 720, ScaleFactor: 1.5, FrameRate: 30,
+		VideoBitrateKbps: 4500, KeyframeIntervalSec: 2, AudioBitrateKbps: 160, AudioSampleRate: 48000,
+	},
+	models.Quality1080p60: {
+		Name: models.Quality1080p60, Width: 1280, Height: 720, ScaleFactor: 1.5, FrameRate: 60,
+		VideoBitrateKbps: 6000, KeyframeIntervalSec: 2, AudioBitrateKbps: 160, AudioSampleRate: 48000,
+	},
+	models.QualityAudioOnly: {
+		Name: models.QualityAudioOnly, Width: 640, Height: 360, ScaleFactor: 1, AudioOnly: true,
+		AudioBitrateKbps: 128, AudioSampleRate: 48000,
+	},
+}
+
+// requestQuality returns the quality profile of a request with its audio
+// options applied, turned on its side for portrait output.
+func requestQuality(request *models.BroadcasterRequest) QualityProfile {
+	profile, ok := qualityProfiles[request.Quality]
+	if !ok {
+		profile = qualityProfiles[defaultQuality]
+	}
+	if request.Orientation == models.OrientationPortrait {
+		profile.Width, profile.Height = profile.Height, profile.Width
+	}
+
+	profile.AudioCodec = models.AudioCodecAAC
+	profile.AudioChannels = 2
+	if audio := request.Audio; audio != nil {
+		if audio.Codec != "" {
+			profile.AudioCodec = audio.Codec
+		}
+		if audio.BitrateKbps != 0 {
+			profile.AudioBitrateKbps = audio.BitrateKbps
+		}
+		if audio.SampleRate != 0 {
+			profile.AudioSampleRate = audio.SampleRate
+		}
+		if audio.Channels != 0 {
+			profile.AudioChannels = audio.Channels
+		}
+	}
+	return profile
+}
+
+// PixelWidth and PixelHeight are the size of the captured picture.
+func (p QualityProfile) PixelWidth() int {
+	return int(float64(p.Width) * p.ScaleFactor)
+}
+
+func (p QualityProfile) PixelHeight() int {
+	return int(float64(p.Height) * p.ScaleFactor)
+}
+
+// ChromeArgs size the window to the profile. Kiosk mode drops the browser
+// frame, so the viewport fills the Moon screen and the captured screen is the
+// page alone. Audio-only bots also turn off what is only needed to draw the
+// page, so more of them fit on a node.
+func (p QualityProfile) ChromeArgs() []string {
+	args := []string{
+		"--kiosk",
+		fmt.Sprintf("--window-size=%d,%d", p.Width, p.Height),
+		"--force-device-scale-factor=" + strconv.FormatFloat(p.ScaleFactor, 'f', -1, 64),
+	}
+	if p.AudioOnly {
+		args = append(args, audioOnlyChromeArgs...)
+	}
+	return args
+}
+
+// ScreenResolution is the Moon screen the browser window is drawn on.
+func (p QualityProfile) ScreenResolution() string {
+	return fmt.Sprintf("%dx%dx24", p.PixelWidth(), p.PixelHeight())
+}
+
+// MoonEnv passes the encoder settings to the capture container. The keyframe
+// interval is given in frames, as ffmpeg's -g expects.
+func (p QualityProfile) MoonEnv() []string {
+	return []string{
+		"QUALITY_PROFILE=" + p.Name,
+		"AUDIO_ONLY=" + strconv.FormatBool(p.AudioOnly),
+		"VIDEO_WIDTH=" + strconv.Itoa(p.PixelWidth()),
+		"VIDEO_HEIGHT=" + strconv.Itoa(p.PixelHeight()),
+		"VIDEO_FRAMERATE=" + strconv.Itoa(p.FrameRate),
+		"VIDEO_BITRATE=" + strconv.Itoa(p.VideoBitrateKbps) + "k",
+		"KEYFRAME_INTERVAL=" + strconv.Itoa(p.FrameRate*p.KeyframeIntervalSec),
+		"AUDIO_CODEC=" + p.AudioCodec,
+		"AUDIO_BITRATE=" + strconv.Itoa(p.AudioBitrateKbps) + "k",
+		"AUDIO_SAMPLE_RATE=" + strconv.Itoa(p.AudioSampleRate),
+		"AUDIO_CHANNELS=" + strconv.Itoa(p.AudioChannels),
+	}
+}
+
+// validateQuality rejects a profile that one of the destinations' platforms
+// or protocols does not accept.
+func validateQuality(profile QualityProfile, destinations []models.Destination) error {
+	err := validateAudio(profile, destinations)
+	if err != nil {
+		return err
+	}
+	for _, destination := range destinations {
+		preset, ok := platformPresets[destination.Platform]
+		if !ok {
+			continue
+		}
+		if profile.AudioOnly && !preset.AudioOnly {
+			return fmt.Errorf("%w: destination %q is on %s, which does not accept audio-only streams", ErrInvalidRequest, destination.Label, destination.Platform)
+		}
+		if preset.MaxFrameRate != 0 && profile.FrameRate > preset.MaxFrameRate {
+			return fmt.Errorf("%w: destination %q is on %s, which accepts at most %d fps, not %s", ErrInvalidRequest, destination.Label, destination.Platform, preset.MaxFrameRate, profile.Name)
+		}
+		if preset.MaxVideoBitrateKbps != 0 && profile.VideoBitrateKbps > preset.MaxVideoBitrateKbps {
+			return fmt.Errorf("%w: destination %q is on %s, which accepts at most %d kbps, not %s", ErrInvalidRequest, destination.Label, destination.Platform, preset.MaxVideoBitrateKbps, profile.Name)
+		}
+	}
+	return nil
+}
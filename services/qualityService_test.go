@@ -0,0 +1,66 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Quality Service", func() {
+	DescribeTable("quality profiles against destination platforms",
+		func(quality string, destination models.Destination, supported bool) {
+			err := services.PrepareDestinations(&models.BroadcasterRequest{
+				Quality:      quality,
+				Destinations: []models.Destination{destination},
+			})
+			if supported {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(services.ErrInvalidRequest))
+			}
+		},
+		Entry("1080p60 on YouTube", models.Quality1080p60,
+			models.Destination{Label: "yt", Platform: models.PlatformYouTube, Key: "abcd-efgh-ijkl-mnop"}, true),
+		Entry("1080p60 on LinkedIn", models.Quality1080p60,
+			models.Destination{Label: "li", Platform: models.PlatformLinkedIn, URL: "rtmps://1234.channel.media.azure.net:2935/live/abcd", Key: "key"}, false),
+		Entry("720p30 on LinkedIn", models.Quality720p30,
+			models.Destination{Label: "li", Platform: models.PlatformLinkedIn, URL: "rtmps://1234.channel.media.azure.net:2935/live/abcd", Key: "key"}, true),
+		Entry("audio only on YouTube", models.QualityAudioOnly,
+			models.Destination{Label: "yt", Platform: models.PlatformYouTube, Key: "abcd-efgh-ijkl-mnop"}, false),
+		Entry("audio only on a custom server", models.QualityAudioOnly,
+			models.Destination{Label: "radio", URL: "rtmp://radio.example.com/live", Key: "stream-123"}, true),
+	)
+
+	DescribeTable("audio settings against destinations",
+		func(quality string, audio *models.AudioOptions, destination models.Destination, supported bool) {
+			err := services.PrepareDestinations(&models.BroadcasterRequest{
+				Quality:      quality,
+				Audio:        audio,
+				Destinations: []models.Destination{destination},
+			})
+			if supported {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(services.ErrInvalidRequest))
+			}
+		},
+		Entry("MP3 to an Icecast mount", models.QualityAudioOnly, &models.AudioOptions{Codec: models.AudioCodecMP3},
+			models.Destination{Label: "radio", URL: "icecast://radio.example.com:8000/live.mp3", Key: "hackme"}, true),
+		Entry("an Icecast mount with video", models.Quality720p30, nil,
+			models.Destination{Label: "radio", URL: "icecast://radio.example.com:8000/live.mp3", Key: "hackme"}, false),
+		Entry("Opus with video", models.Quality720p30, &models.AudioOptions{Codec: models.AudioCodecOpus},
+			models.Destination{Label: "srt", URL: "srt://ingest.example.com:9000"}, false),
+		Entry("Opus over RTMP", models.QualityAudioOnly, &models.AudioOptions{Codec: models.AudioCodecOpus},
+			models.Destination{Label: "radio", URL: "rtmp://radio.example.com/live", Key: "stream-123"}, false),
+	)
+
+	It("should describe the encoder settings to the capture container", func() {
+		profile := services.QualityProfile{Name: models.Quality1080p30, Width: 1280, Height: 720, ScaleFactor: 1.5, FrameRate: 30, VideoBitrateKbps: 4500, KeyframeIntervalSec: 2, AudioBitrateKbps: 160, AudioSampleRate: 48000}
+
+		Expect(profile.ScreenResolution()).To(Equal("1920x1080x24"))
+		Expect(profile.ChromeArgs()).To(ContainElement("--force-device-scale-factor=1.5"))
+		Expect(profile.MoonEnv()).To(ContainElements("VIDEO_WIDTH=1920", "VIDEO_BITRATE=4500k", "KEYFRAME_INTERVAL=60", "AUDIO_SAMPLE_RATE=48000"))
+	})
+})
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"spoutbreeze/models"
+)
+
+const (
+	defaultRecordingsDir       = "/recordings"
+	defaultRecordingRetention  = 7 * 24 * time.Hour
+	recordingRetentionInterval = time.Hour
+)
+
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// recordingTeeOptions are the tee muxer options of each recording format.
+// MP4 is fragmented so a file cut short by a crash can still be played.
+var recordingTeeOptions = map[string]string{
+	models.RecordingFormatMP4: "f=mp4:movflags=+frag_keyframe+empty_moov",
+	models.RecordingFormatMKV: "f=matroska",
+}
+
+// recordingsDir is where this service finds the recordings on the volume it
+// shares with the capture containers.
+func recordingsDir() string {
+	if dir := os.Getenv("RECORDINGS_DIR"); dir != "" {
+		return dir
+	}
+	return defaultRecordingsDir
+}
+
+// captureRecordingsDir is the same volume as mounted in the capture
+// container.
+func captureRecordingsDir() string {
+	if dir := os.Getenv("CAPTURE_RECORDINGS_DIR"); dir != "" {
+		return dir
+	}
+	return recordingsDir()
+}
+
+// recordingRetention is how long recordings are kept, from
+// RECORDING_RETENTION_HOURS. Zero keeps them forever.
+func recordingRetention() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("RECORDING_RETENTION_HOURS"))
+	if err != nil || hours < 0 {
+		return defaultRecordingRetention
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// startRecording registers a new recording when the request asks for one and
+// returns the capture container's environment, its tee output and its name.
+// The recording is written next to the live outputs, so a failing
+// destination does not stop it.
+func startRecording(session *Session) ([]string, []string, string) {
+	if !session.Request.Record {
+		return nil, nil, ""
+	}
+	format := session.Request.RecordingFormat
+	if format == "" {
+		format = models.RecordingFormatMP4
+	}
+
+	recording := session.addRecording(format, models.RecordingSourceCapture)
+	err := os.MkdirAll(filepath.Join(recordingsDir(), session.ID), 0o755)
+	if err != nil {
+		log.Printf("Warning: Failed to create recordings directory: %v", err)
+	}
+
+	path := filepath.Join(captureRecordingsDir(), session.ID, recording.Name)
+	env := []string{
+		"RECORD=true",
+		"RECORDING_FORMAT=" + format,
+		"RECORDING_PATH=" + path,
+	}
+	return env, []string{"[" + recordingTeeOptions[format] + ":onfail=ignore]" + path}, recording.Name
+}
+
+// discardRecording marks a capture recording whose browser never started as
+// failed. The session's other recordings, such as those of the browser a
+// restart would replace, carry on.
+func discardRecording(session *Session, name string) {
+	if name == "" {
+		return
+	}
+	endedAt := time.Now()
+	session.setRecordingState(name, models.RecordingStateFailed, &endedAt)
+}
+
+func (s *Session) addRecording(format string, source string) models.Recording {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	startedAt := time.Now()
+	name := fmt.Sprintf("%s-%d.%s", startedAt.UTC().Format("20060102T150405Z"), len(s.recordings)+1, format)
+	if source == models.RecordingSourceMoonVideo {
+		name = "moon-" + name
+	}
+	recording := models.Recording{
+		Name:      name,
+		Source:    source,
+		Format:    format,
+		State:     models.RecordingStateRecording,
+		StartedAt: startedAt,
+	}
+	s.recordings = append(s.recordings, recording)
+	return recording
+}
+
+// stopRecording marks the running recording as completed once the capture
+// container is gone.
+func (s *Session) stopRecording() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.recordings {
+		recording := &s.recordings[i]
+		if recording.Source == models.RecordingSourceCapture && recording.State == models.RecordingStateRecording {
+			endedAt := time.Now()
+			recording.State = models.RecordingStateCompleted
+			recording.EndedAt = &endedAt
+		}
+	}
+}
+
+// setRecordingState updates a recording, and its end time when one is given.
+func (s *Session) setRecordingState(name string, state string, endedAt *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.recordings {
+		if s.recordings[i].Name == name {
+			s.recordings[i].State = state
+			if endedAt != nil {
+				s.recordings[i].EndedAt = endedAt
+			}
+		}
+	}
+}
+
+// Recordings lists the session's recordings with their current size and
+// duration. A running recording is measured up to now.
+func (s *Session) Recordings() []models.Recording {
+	s.mu.Lock()
+	recordings := append([]models.Recording{}, s.recordings...)
+	s.mu.Unlock()
+
+	for i := range recordings {
+		recording := &recordings[i]
+		endedAt := time.Now()
+		if recording.EndedAt != nil {
+			endedAt = *recording.EndedAt
+		}
+		recording.DurationSeconds = endedAt.Sub(recording.StartedAt).Round(time.Second).Seconds()
+		if info, err := os.Stat(filepath.Join(recordingsDir(), s.ID, recording.Name)); err == nil {
+			recording.SizeBytes = info.Size()
+		}
+	}
+	return recordings
+}
+
+// RecordingFile returns the path of a recording of the session for download.
+func (s *Session) RecordingFile(name string) (string, error) {
+	for _, recording := range s.Recordings() {
+		if recording.Name != name || recording.State == models.RecordingStateDeleted {
+			continue
+		}
+		path := filepath.Join(recordingsDir(), s.ID, recording.Name)
+		if _, err := os.Stat(path); err != nil {
+			return "", ErrRecordingNotFound
+		}
+		return path, nil
+	}
+	return "", ErrRecordingNotFound
+}
+
+// StartRecordingRetention removes expired recordings every hour.
+func StartRecordingRetention() {
+	go func() {
+		for {
+			DeleteExpiredRecordings(time.Now())
+			time.Sleep(recordingRetentionInterval)
+		}
+	}()
+}
+
+// DeleteExpiredRecordings removes the recordings last written to before the
+// retention period and returns how many were removed. Recordings still being
+// written are always newer than that.
+func DeleteExpiredRecordings(now time.Time) int {
+	retention := recordingRetention()
+	if retention == 0 {
+		return 0
+	}
+
+	deleted := 0
+	root := recordingsDir()
+	sessionDirs, err := os.ReadDir(root)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Warning: Failed to read recordings directory: %v", err)
+		}
+		return 0
+	}
+	for _, sessionDir := range sessionDirs {
+		if !sessionDir.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, sessionDir.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			log.Printf("Warning: Failed to read recordings of session %s: %v", sessionDir.Name(), err)
+			continue
+		}
+		remaining, removed := len(files), 0
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil || file.IsDir() || now.Sub(info.ModTime()) < retention {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+				log.Printf("Warning: Failed to delete recording %s: %v", file.Name(), err)
+				continue
+			}
+			deleted++
+			removed++
+			remaining--
+			if session, ok := GetSession(sessionDir.Name()); ok {
+				session.setRecordingState(file.Name(), models.RecordingStateDeleted, nil)
+				session.AddEvent("recording_deleted", file.Name()+" reached the retention period")
+			}
+		}
+		if remaining > 0 {
+			continue
+		}
+		// A new session's directory is empty until its capture container
+		// writes, it is only removed once it is as old as a recording would be
+		if removed == 0 {
+			info, err := sessionDir.Info()
+			if err != nil || now.Sub(info.ModTime()) < retention {
+				continue
+			}
+		}
+		os.Remove(dir)
+	}
+	return deleted
+}
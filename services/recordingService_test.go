@@ -0,0 +1,74 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Recording Service", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		GinkgoT().Setenv("RECORDINGS_DIR", dir)
+		GinkgoT().Setenv("RECORDING_RETENTION_HOURS", "24")
+	})
+
+	writeRecording := func(sessionID string, name string, age time.Duration) string {
+		path := filepath.Join(dir, sessionID, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte("recording"), 0o644)).To(Succeed())
+		modified := time.Now().Add(-age)
+		Expect(os.Chtimes(path, modified, modified)).To(Succeed())
+		return path
+	}
+
+	It("should delete recordings older than the retention period", func() {
+		expired := writeRecording("old-session", "20260101T100000Z-1.mp4", 48*time.Hour)
+		kept := writeRecording("new-session", "20260103T100000Z-1.mkv", time.Hour)
+
+		Expect(services.DeleteExpiredRecordings(time.Now())).To(Equal(1))
+		Expect(expired).NotTo(BeAnExistingFile())
+		Expect(filepath.Dir(expired)).NotTo(BeADirectory())
+		Expect(kept).To(BeAnExistingFile())
+	})
+
+	It("should only remove empty directories older than the retention", func() {
+		fresh := filepath.Join(dir, "new-session")
+		stale := filepath.Join(dir, "old-session")
+		Expect(os.MkdirAll(fresh, 0o755)).To(Succeed())
+		Expect(os.MkdirAll(stale, 0o755)).To(Succeed())
+		old := time.Now().Add(-48 * time.Hour)
+		Expect(os.Chtimes(stale, old, old)).To(Succeed())
+
+		Expect(services.DeleteExpiredRecordings(time.Now())).To(Equal(0))
+		Expect(fresh).To(BeADirectory())
+		Expect(stale).NotTo(BeADirectory())
+	})
+
+	It("should keep recordings when retention is turned off", func() {
+		GinkgoT().Setenv("RECORDING_RETENTION_HOURS", "0")
+		kept := writeRecording("old-session", "20260101T100000Z-1.mp4", 48*time.Hour)
+
+		Expect(services.DeleteExpiredRecordings(time.Now())).To(Equal(0))
+		Expect(kept).To(BeAnExistingFile())
+	})
+
+	It("should not serve files that are not recordings of the session", func() {
+		session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf", Record: true})
+		writeRecording(session.ID, "other.mp4", time.Minute)
+
+		Expect(session.Recordings()).To(BeEmpty())
+		_, err := session.RecordingFile("other.mp4")
+		Expect(err).To(MatchError(services.ErrRecordingNotFound))
+		_, err = session.RecordingFile("../../etc/passwd")
+		Expect(err).To(MatchError(services.ErrRecordingNotFound))
+	})
+})
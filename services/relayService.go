@@ -0,0 +1,123 @@
+package services
+
+import (
+	"log"
+	"net"
+	"os"
+
+	"spoutbreeze/models"
+	"spoutbreeze/relay"
+)
+
+// relayLabel is the destination the capture container pushes to when the
+// relay is on.
+const relayLabel = "relay"
+
+// rtmpRelay is the embedded RTMP relay, nil unless RTMP_RELAY_ADDR is set.
+var rtmpRelay *relay.Relay
+
+// StartRTMPRelay starts the embedded relay on RTMP_RELAY_ADDR. Capture
+// containers then push once to the relay, which keeps each RTMP and RTMPS
+// destination connected on its own.
+func StartRTMPRelay() {
+	address := os.Getenv("RTMP_RELAY_ADDR")
+	if address == "" {
+		return
+	}
+	rtmpRelay = relay.New()
+	go func() {
+		err := rtmpRelay.ListenAndServe(address)
+		if err != nil && err != relay.ErrServerClosed {
+			log.Printf("RTMP relay stopped: %v", err)
+		}
+	}()
+	log.Printf("RTMP relay listening on %s, capture containers push to %s", address, relayURL())
+}
+
+// relayURL is the relay's ingest as the capture containers reach it,
+// RTMP_RELAY_URL or the relay port on CLUSTER_IP.
+func relayURL() string {
+	if relayURL := os.Getenv("RTMP_RELAY_URL"); relayURL != "" {
+		return relayURL
+	}
+	_, port, err := net.SplitHostPort(os.Getenv("RTMP_RELAY_ADDR"))
+	if err != nil {
+		port = "1935"
+	}
+	return "rtmp://" + net.JoinHostPort(os.Getenv("CLUSTER_IP"), port) + "/live"
+}
+
+// captureDestinations are the outputs of the session's capture container.
+// With the relay on, the RTMP and RTMPS destinations are registered with it
+// under the session's relay key and replaced by a single push to the relay
+// under relayKey, the session's relay key or the standby key of a
+// replacement browser. Other protocols are still pushed directly.
+func captureDestinations(session *Session, relayKey string) []models.Destination {
+	destinations := requestDestinations(session.Request)
+	if rtmpRelay == nil {
+		return destinations
+	}
+
+	var relayed []relay.Destination
+	var direct []models.Destination
+	for _, destination := range destinations {
+		switch DestinationProtocol(destination) {
+		case models.ProtocolRTMP, models.ProtocolRTMPS:
+			relayed = append(relayed, relay.Destination{Label: destination.Label, URL: destination.URL, Key: destination.Key})
+		default:
+			direct = append(direct, destination)
+		}
+	}
+	if len(relayed) == 0 {
+		return destinations
+	}
+
+	rtmpRelay.Register(session.relayKey, relayed, requestDelay(session.Request), func(label string, state string, message string) {
+		switch state {
+		case relay.StateLive:
+			session.setDestinationStatus(label, models.DestinationStateLive, "")
+		case relay.StateReconnecting:
+			session.setDestinationStatus(label, models.DestinationStateReconnecting, message)
+		}
+	})
+	if relayKey != session.relayKey {
+		err := rtmpRelay.AddStandby(session.relayKey, relayKey)
+		if err != nil {
+			log.Printf("Warning: Failed to add relay standby %s: %v", relayKey, err)
+		}
+	}
+	return append([]models.Destination{{Label: relayLabel, URL: relayURL(), Key: relayKey}}, direct...)
+}
+
+// stopRelay disconnects the session's relayed destinations.
+func stopRelay(session *Session) {
+	if rtmpRelay != nil {
+		rtmpRelay.Unregister(session.relayKey)
+	}
+}
+
+// removeStandby forgets the standby key of a replacement browser that did
+// not take over, so nothing can publish to the destinations under it.
+func removeStandby(session *Session, standbyKey string) {
+	if rtmpRelay != nil {
+		// An unknown key was never added, which leaves nothing to remove
+		rtmpRelay.RemoveStandby(session.relayKey, standbyKey)
+	}
+}
+
+// addRelayStats fills in the traffic of relayed destinations.
+func addRelayStats(relayKey string, statuses []models.DestinationStatus) {
+	if rtmpRelay == nil {
+		return
+	}
+	for _, stats := range rtmpRelay.Stats(relayKey) {
+		for i := range statuses {
+			if statuses[i].Label != stats.Label {
+				continue
+			}
+			statuses[i].BytesSent = stats.BytesSent
+			statuses[i].BitrateKbps = stats.BitrateKbps
+			statuses[i].Reconnects = stats.Reconnects
+		}
+	}
+}
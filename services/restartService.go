@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+// restartSwitchTimeout bounds how long the replacement's capture container
+// has to start pushing to the relay after the bot joined. It is shortened by
+// the tests that drive a restart with a fake browser.
+var restartSwitchTimeout = time.Minute
+
+// startReplacementBrowser starts the replacement browser of a restart, swapped
+// for a fake browser by the tests.
+var startReplacementBrowser = startBotBrowser
+
+var (
+	ErrRestartUnavailable = errors.New("restart without dropping the stream is not available")
+	ErrRestartInProgress  = errors.New("a restart is already in progress")
+)
+
+// errRestartRequested stops monitorMeeting so the session's stream loop can
+// run a requested restart.
+var errRestartRequested = errors.New("restart requested")
+
+// RestartSession asks the session's stream loop for a make-before-break
+// restart: a replacement browser joins the meeting and publishes to the RTMP
+// relay next to the current one, and the relay hands the destinations over to
+// it before the current browser is retired. The destinations stay connected
+// throughout. It only works when every output goes through the relay, and
+// returns once the restart is queued.
+func RestartSession(sessionID string) error {
+	session, ok := GetSession(sessionID)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if rtmpRelay == nil {
+		return fmt.Errorf("%w: it needs the RTMP relay, set RTMP_RELAY_ADDR", ErrRestartUnavailable)
+	}
+	if session.Type != models.SessionTypeBroadcast {
+		return fmt.Errorf("%w: test broadcasts cannot be restarted", ErrRestartUnavailable)
+	}
+	if len(session.Request.WHIPDestinations) > 0 {
+		return fmt.Errorf("%w: WHIP destinations are published by the browser itself", ErrRestartUnavailable)
+	}
+	for _, destination := range requestDestinations(session.Request) {
+		protocol := DestinationProtocol(destination)
+		if protocol != models.ProtocolRTMP && protocol != models.ProtocolRTMPS {
+			return fmt.Errorf("%w: destination %q is pushed by the capture container, not the relay", ErrRestartUnavailable, destination.Label)
+		}
+	}
+	if session.Driver() == nil || session.State() != models.SessionStateLive {
+		return ErrSessionNotLive
+	}
+
+	if !session.requestRestart() {
+		return ErrRestartInProgress
+	}
+	session.AddEvent("restart_requested", "a replacement browser will be started")
+	return nil
+}
+
+func (s *Session) requestRestart() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restartPending {
+		return false
+	}
+	s.restartPending = true
+	return true
+}
+
+func (s *Session) restartRequested() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restartPending
+}
+
+func (s *Session) finishRestart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restartPending = false
+}
+
+// newStandbyKey is the relay stream key of a replacement browser, random
+// like the session's own relay key.
+func newStandbyKey() string {
+	return newSessionID()
+}
+
+// activeRecordings are the names of the recordings still being written,
+// which belong to the running browser.
+func (s *Session) activeRecordings() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := map[string]bool{}
+	for _, recording := range s.recordings {
+		if recording.State == models.RecordingStateRecording && recording.EndedAt == nil {
+			names[recording.Name] = true
+		}
+	}
+	return names
+}
+
+// restartBotBrowser starts and joins a replacement for the session's browser
+// and switches the relay over to it. The current browser keeps streaming
+// until the switch; on failure it stays on, the replacement is retired and
+// its standby key is forgotten.
+func restartBotBrowser(session *Session, current selenium.WebDriver) (selenium.WebDriver, error) {
+	session.AddEvent("restart_started", "starting a replacement browser")
+	// Joining through the guest lobby moves the state, the current browser is live throughout
+	defer func() {
+		if session.State() != models.SessionStateLive {
+			session.SetState(models.SessionStateLive, "")
+		}
+	}()
+
+	currentRecordings := session.activeRecordings()
+	standbyKey := newStandbyKey()
+	driver, err := startReplacementBrowser(session, standbyKey)
+	if err != nil {
+		removeStandby(session, standbyKey)
+		session.AddEvent("restart_failed", err.Error())
+		return nil, err
+	}
+	replacementRecordings := session.activeRecordings()
+	for name := range currentRecordings {
+		delete(replacementRecordings, name)
+	}
+
+	err = joinMeeting(driver, session)
+	if err == nil {
+		applyDumpSlate(driver, session)
+		err = rtmpRelay.Switch(session.relayKey, standbyKey, restartSwitchTimeout)
+	}
+	if err != nil {
+		retireBotBrowser(session, driver, replacementRecordings)
+		removeStandby(session, standbyKey)
+		session.AddEvent("restart_failed", err.Error())
+		return nil, err
+	}
+
+	session.setDriver(driver)
+	retireBotBrowser(session, current, currentRecordings)
+	session.AddEvent("restart_completed", "switched to Moon session "+driver.SessionID())
+	return driver, nil
+}
+
+// retireBotBrowser quits a browser that is no longer on air and closes its
+// recordings.
+func retireBotBrowser(session *Session, driver selenium.WebDriver, recordings map[string]bool) {
+	err := driver.Quit()
+	if err != nil {
+		log.Printf("Warning: Failed to quit the replaced browser: %v", err)
+	}
+	endedAt := time.Now()
+	for _, recording := range session.Recordings() {
+		if !recordings[recording.Name] {
+			continue
+		}
+		if recording.Source == models.RecordingSourceMoonVideo {
+			fetchMoonVideo(session, recording.Name, endedAt)
+		} else {
+			session.setRecordingState(recording.Name, models.RecordingStateCompleted, &endedAt)
+		}
+	}
+}
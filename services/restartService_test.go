@@ -0,0 +1,191 @@
+package services_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tebeka/selenium"
+
+	"spoutbreeze/models"
+	"spoutbreeze/relay"
+	"spoutbreeze/services"
+)
+
+// fakeBotBrowser is a bot's browser showing the BBB client, which it joins
+// without a guest lobby. It remembers being quit.
+type fakeBotBrowser struct {
+	*fakePage
+	id   string
+	quit bool
+}
+
+func (b *fakeBotBrowser) SessionID() string {
+	return b.id
+}
+
+func (b *fakeBotBrowser) Quit() error {
+	b.quit = true
+	return nil
+}
+
+var _ = Describe("Restart Service", func() {
+	It("should return ErrSessionNotFound for unknown sessions", func() {
+		err := services.RestartSession("unknown")
+		Expect(err).To(MatchError(services.ErrSessionNotFound))
+	})
+
+	It("should need the RTMP relay", func() {
+		session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://live.twitch.tv/app", StreamKey: "live_123456789_AbCdEf"})
+
+		err := services.RestartSession(session.ID)
+		Expect(err).To(MatchError(services.ErrRestartUnavailable))
+		Expect(err.Error()).To(ContainSubstring("RTMP_RELAY_ADDR"))
+	})
+
+	Describe("restarting the bot's browser", func() {
+		var (
+			relayURL    string
+			rtmpRelay   *relay.Relay
+			session     *services.Session
+			current     *fakeBotBrowser
+			replacement *fakeBotBrowser
+			standbyKey  string
+			publishing  bool
+		)
+
+		// publishStandby streams keyframes to the relay under the standby key
+		// until the test ends, as the replacement's capture container would.
+		publishStandby := func() {
+			publisher, err := relay.Dial(relayURL, standbyKey, 5*time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			done := make(chan struct{})
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				for frame := 0; ; frame++ {
+					keyframe := &relay.Message{Type: relay.MessageVideo, Timestamp: uint32(frame * 40), Payload: []byte{0x17, 0x01, 'K'}}
+					if publisher.WriteMessage(keyframe, time.Second) != nil {
+						return
+					}
+					select {
+					case <-done:
+						return
+					case <-time.After(20 * time.Millisecond):
+					}
+				}
+			}()
+			DeferCleanup(func() {
+				close(done)
+				<-stopped
+				publisher.Close()
+			})
+		}
+
+		eventTypes := func() []string {
+			var types []string
+			for _, event := range session.Snapshot().Events {
+				types = append(types, event.Type)
+			}
+			return types
+		}
+
+		recordingStates := func() []string {
+			var states []string
+			for _, recording := range session.Recordings() {
+				states = append(states, recording.State)
+			}
+			return states
+		}
+
+		BeforeEach(func() {
+			GinkgoT().Setenv("RECORDINGS_DIR", GinkgoT().TempDir())
+			DeferCleanup(services.ShortenMonitorTimings(10*time.Millisecond, time.Second))
+			DeferCleanup(services.ShortenRestartSwitch(300 * time.Millisecond))
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			rtmpRelay = relay.New()
+			go rtmpRelay.Serve(listener)
+			DeferCleanup(rtmpRelay.Close)
+			DeferCleanup(services.UseRelay(rtmpRelay))
+			relayURL = "rtmp://" + listener.Addr().String() + "/live"
+
+			// The current browser is live and records through its capture container
+			session = services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://127.0.0.1:1/app", StreamKey: "live_123", Record: true})
+			services.CaptureDestinations(session, session.RelayKey())
+			services.StartRecording(session)
+			current = &fakeBotBrowser{fakePage: &fakePage{}, id: "moon-current"}
+			session.SetDriver(current)
+			session.SetState(models.SessionStateLive, "")
+
+			replacement = &fakeBotBrowser{fakePage: &fakePage{}, id: "moon-replacement"}
+			publishing = true
+			DeferCleanup(services.FakeReplacementBrowser(func(session *services.Session, relayKey string) (selenium.WebDriver, error) {
+				standbyKey = relayKey
+				services.CaptureDestinations(session, relayKey)
+				services.StartRecording(session)
+				if publishing {
+					publishStandby()
+				}
+				// Joining moves the state, as the guest lobby would
+				session.SetState(models.SessionStateStarting, "")
+				return replacement, nil
+			}))
+		})
+
+		It("should switch to the replacement before retiring the current browser", func() {
+			driver, err := services.RestartBotBrowser(session, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(driver).To(BeIdenticalTo(replacement))
+			Expect(session.Driver()).To(BeIdenticalTo(replacement))
+			Expect(standbyKey).NotTo(Equal(session.RelayKey()))
+
+			Expect(current.quit).To(BeTrue())
+			Expect(replacement.quit).To(BeFalse())
+			// The current browser's recording is closed, the replacement's goes on
+			Expect(recordingStates()).To(Equal([]string{models.RecordingStateCompleted, models.RecordingStateRecording}))
+			Expect(session.State()).To(Equal(models.SessionStateLive))
+			Expect(eventTypes()).To(ContainElement("restart_completed"))
+		})
+
+		It("should keep the current browser and forget the standby when the replacement is not live", func() {
+			publishing = false
+
+			driver, err := services.RestartBotBrowser(session, current)
+			Expect(err).To(MatchError(relay.ErrSourceNotLive))
+			Expect(driver).To(BeNil())
+			Expect(session.Driver()).To(BeIdenticalTo(current))
+
+			Expect(current.quit).To(BeFalse())
+			Expect(replacement.quit).To(BeTrue())
+			Expect(recordingStates()).To(Equal([]string{models.RecordingStateRecording, models.RecordingStateCompleted}))
+			Expect(session.State()).To(Equal(models.SessionStateLive))
+			Expect(eventTypes()).To(ContainElement("restart_failed"))
+
+			// Nothing can publish to the destinations under the standby key any more
+			_, err = relay.Dial(relayURL, standbyKey, 5*time.Second)
+			Expect(err).To(MatchError(ContainSubstring("NetStream.Publish.BadName")))
+		})
+
+		It("should forget the standby when the replacement does not start", func() {
+			DeferCleanup(services.FakeReplacementBrowser(func(session *services.Session, relayKey string) (selenium.WebDriver, error) {
+				standbyKey = relayKey
+				services.CaptureDestinations(session, relayKey)
+				return nil, errors.New("error starting browser: moon is full")
+			}))
+
+			_, err := services.RestartBotBrowser(session, current)
+			Expect(err).To(MatchError(ContainSubstring("moon is full")))
+			Expect(session.Driver()).To(BeIdenticalTo(current))
+			Expect(current.quit).To(BeFalse())
+			Expect(session.State()).To(Equal(models.SessionStateLive))
+			Expect(eventTypes()).To(ContainElement("restart_failed"))
+
+			_, err = relay.Dial(relayURL, standbyKey, 5*time.Second)
+			Expect(err).To(MatchError(ContainSubstring("NetStream.Publish.BadName")))
+		})
+	})
+})
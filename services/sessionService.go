@@ -0,0 +1,256 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+const (
+	maxSessionBrowserLogs = 500
+
+	// Ended and failed sessions stay in the registry for this long so that
+	// their status, events and logs can still be fetched.
+	sessionRetention        = 24 * time.Hour
+	sessionEvictionInterval = time.Hour
+)
+
+// Session tracks a single broadcast bot from launch until it ends.
+type Session struct {
+	ID      string
+	Type    models.SessionType
+	Request *models.BroadcasterRequest
+	// relayKey is the stream key the capture container pushes to the RTMP
+	// relay under. Whoever knows it can publish to the destinations, so it
+	// is random and never reported.
+	relayKey string
+
+	mu             sync.Mutex
+	state          models.SessionState
+	reason         string
+	diagnosis      string
+	startedAt      time.Time
+	endedAt        time.Time
+	events         []models.SessionEvent
+	browserLogs    []models.BrowserLogEntry
+	flaggedErrors  map[string]bool
+	overlays       []models.Overlay
+	layoutAction   string
+	destinations   []models.DestinationStatus
+	whipSessions   map[string]*WHIPSession
+	whipRetries    map[string]*whipRetry
+	testDuration   time.Duration
+	parentID       string
+	companions     []string
+	recordings     []models.Recording
+	dumpedAt       *time.Time
+	cover          *models.Slate
+	fallbackSince  *time.Time
+	restartPending bool
+	driver         selenium.WebDriver
+}
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionNotLive  = errors.New("session is not live")
+	ErrInvalidRequest  = errors.New("invalid broadcaster request")
+)
+
+var (
+	sessionsMu    sync.RWMutex
+	sessions      = map[string]*Session{}
+	evictionStart sync.Once
+)
+
+// SessionError is a session failure carrying a reason code for API clients.
+type SessionError struct {
+	Reason string
+	Err    error
+}
+
+func (e *SessionError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *SessionError) Unwrap() error {
+	return e.Err
+}
+
+// NewSession creates a session for the request and registers it.
+func NewSession(request *models.BroadcasterRequest) *Session {
+	return newSession(request, models.SessionTypeBroadcast)
+}
+
+func newSession(request *models.BroadcasterRequest, sessionType models.SessionType) *Session {
+	session := &Session{
+		ID:            newSessionID(),
+		relayKey:      newSessionID(),
+		Type:          sessionType,
+		Request:       request,
+		state:         models.SessionStateStarting,
+		startedAt:     time.Now(),
+		flaggedErrors: map[string]bool{},
+		overlays:      append([]models.Overlay{}, request.Overlays...),
+		destinations:  newDestinationStatuses(requestDestinations(request), request.WHIPDestinations),
+	}
+
+	sessionsMu.Lock()
+	sessions[session.ID] = session
+	sessionsMu.Unlock()
+	evictionStart.Do(startSessionEviction)
+
+	return session
+}
+
+func newSessionID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(buf)
+}
+
+func GetSession(id string) (*Session, bool) {
+	sessionsMu.RLock()
+	defer sessionsMu.RUnlock()
+	session, ok := sessions[id]
+	return session, ok
+}
+
+// startSessionEviction removes expired sessions from the registry every hour.
+// It is started with the first session.
+func startSessionEviction() {
+	go func() {
+		for {
+			EvictEndedSessions(time.Now())
+			time.Sleep(sessionEvictionInterval)
+		}
+	}()
+}
+
+// EvictEndedSessions removes the sessions that ended or failed more than
+// sessionRetention before now and returns how many were removed.
+func EvictEndedSessions(now time.Time) int {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	evicted := 0
+	for id, session := range sessions {
+		session.mu.Lock()
+		endedAt := session.endedAt
+		session.mu.Unlock()
+		if endedAt.IsZero() || now.Sub(endedAt) < sessionRetention {
+			continue
+		}
+		delete(sessions, id)
+		evicted++
+	}
+	return evicted
+}
+
+func (s *Session) State() models.SessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *Session) SetState(state models.SessionState, reason string) {
+	s.mu.Lock()
+	s.state = state
+	s.reason = reason
+	if state == models.SessionStateEnded || state == models.SessionStateFailed {
+		s.endedAt = time.Now()
+	}
+	s.mu.Unlock()
+
+	message := string(state)
+	if reason != "" {
+		message += ": " + reason
+	}
+	s.AddEvent("state_changed", message)
+}
+
+func (s *Session) AddEvent(eventType string, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, models.SessionEvent{
+		Time:    time.Now(),
+		Type:    eventType,
+		Message: message,
+	})
+}
+
+// addBrowserLogs appends captured browser logs, keeping only the most recent
+// entries, and flags the first occurrence of each fatal client error.
+func (s *Session) addBrowserLogs(entries []models.BrowserLogEntry) {
+	var flagged []models.BrowserLogEntry
+
+	s.mu.Lock()
+	for _, entry := range entries {
+		if entry.Fatal && !s.flaggedErrors[entry.Reason] {
+			s.flaggedErrors[entry.Reason] = true
+			if s.diagnosis == "" {
+				s.diagnosis = entry.Reason
+			}
+			flagged = append(flagged, entry)
+		}
+	}
+	s.browserLogs = append(s.browserLogs, entries...)
+	if len(s.browserLogs) > maxSessionBrowserLogs {
+		s.browserLogs = s.browserLogs[len(s.browserLogs)-maxSessionBrowserLogs:]
+	}
+	s.mu.Unlock()
+
+	for _, entry := range flagged {
+		s.AddEvent("client_error", entry.Reason+": "+entry.Message)
+	}
+}
+
+// Driver returns the session's WebDriver, or nil while no browser is running.
+func (s *Session) Driver() selenium.WebDriver {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.driver
+}
+
+func (s *Session) setDriver(driver selenium.WebDriver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.driver = driver
+}
+
+// Snapshot returns a copy of the session that is safe to serialize.
+// The relay stats are added after the lock is released, as the relay may be
+// busy with a stalled destination.
+func (s *Session) Snapshot() models.BroadcasterSession {
+	snapshot := s.snapshot()
+	addRelayStats(s.relayKey, snapshot.Destinations)
+	return snapshot
+}
+
+func (s *Session) snapshot() models.BroadcasterSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return models.BroadcasterSession{
+		ID:                  s.ID,
+		Type:                s.Type,
+		ParentSessionID:     s.parentID,
+		CompanionSessionIDs: append([]string{}, s.companions...),
+		State:               s.state,
+		Reason:              s.reason,
+		Diagnosis:           s.diagnosis,
+		StartedAt:           s.startedAt,
+		Events:              append([]models.SessionEvent{}, s.events...),
+		BrowserLogs:         append([]models.BrowserLogEntry{}, s.browserLogs...),
+		Overlays:            append([]models.Overlay{}, s.overlays...),
+		Destinations:        append([]models.DestinationStatus{}, s.destinations...),
+		Debug:               s.debugEndpoints(),
+		Delay:               s.delayStatus(),
+		FallbackSince:       s.fallbackSince,
+	}
+}
@@ -0,0 +1,36 @@
+package services_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Session Service", func() {
+	Describe("EvictEndedSessions", func() {
+		It("should remove sessions only once they ended longer ago than the retention", func() {
+			ended := services.NewSession(&models.BroadcasterRequest{})
+			ended.SetState(models.SessionStateEnded, models.ReasonMeetingEnded)
+			failed := services.NewSession(&models.BroadcasterRequest{})
+			failed.SetState(models.SessionStateFailed, "error")
+			live := services.NewSession(&models.BroadcasterRequest{})
+			live.SetState(models.SessionStateLive, "")
+
+			services.EvictEndedSessions(time.Now().Add(time.Hour))
+			_, ok := services.GetSession(ended.ID)
+			Expect(ok).To(BeTrue())
+
+			services.EvictEndedSessions(time.Now().Add(25 * time.Hour))
+			_, ok = services.GetSession(ended.ID)
+			Expect(ok).To(BeFalse())
+			_, ok = services.GetSession(failed.ID)
+			Expect(ok).To(BeFalse())
+			_, ok = services.GetSession(live.ID)
+			Expect(ok).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+const defaultEndedSlateSeconds = 30
+
+// Timings of the slates, shortened by the tests that drive them with a fake
+// browser.
+var (
+	meetingStartPollInterval = 10 * time.Second
+	// meetingStartTimeout is how long the starting slate waits for the
+	// meeting, from the countdown's start time when it has one
+	meetingStartTimeout    = 2 * time.Hour
+	navigationPollInterval = 50 * time.Millisecond
+	navigationTimeout      = 30 * time.Second
+	// slateFade matches the opacity transition of slateScript
+	slateFade = time.Second
+)
+
+// slateScript covers the page with a slate and mutes every audio and video
+// element, including ones the client adds later, so neither the meeting's
+// picture nor its sound is captured. The slate's own video is left playing.
+// Clicks go through the slate to the page underneath. The card is only
+// rebuilt when the slate changes, as it is reinstalled on every monitor
+// tick. With arguments[1] a new card fades in.
+const slateScript = `
+var slate = arguments[0];
+var card = document.getElementById('spoutbreeze-slate');
+if (!card) {
+	card = document.createElement('div');
+	card.id = 'spoutbreeze-slate';
+	card.style.cssText = 'position:fixed;top:0;left:0;width:100vw;height:100vh;z-index:2147483647;display:flex;flex-direction:column;align-items:center;justify-content:center;gap:32px;color:#fff;font:600 48px sans-serif;text-align:center;pointer-events:none;transition:opacity 1s;';
+	if (arguments[1]) {
+		card.style.opacity = '0';
+	}
+	document.body.appendChild(card);
+	card.getBoundingClientRect();
+	card.style.opacity = '1';
+}
+var key = JSON.stringify(slate);
+if (card.dataset.slate !== key) {
+	card.dataset.slate = key;
+	clearInterval(window.__spoutbreezeSlateCountdown);
+	card.style.background = slate.background || '#000';
+	card.innerHTML = '';
+	if (slate.video_url) {
+		var video = document.createElement('video');
+		video.src = slate.video_url;
+		video.loop = true;
+		video.autoplay = true;
+		video.playsInline = true;
+		video.dataset.spoutbreezeSlateMedia = '1';
+		video.style.cssText = 'position:absolute;top:0;left:0;width:100%;height:100%;object-fit:cover;z-index:-1;';
+		card.appendChild(video);
+		video.play().catch(function () {});
+	}
+	if (slate.page_url) {
+		var frame = document.createElement('iframe');
+		frame.src = slate.page_url;
+		frame.style.cssText = 'position:absolute;top:0;left:0;width:100%;height:100%;border:0;';
+		card.appendChild(frame);
+	}
+	if (slate.image_url) {
+		var image = document.createElement('img');
+		image.src = slate.image_url;
+		image.style.cssText = 'max-width:60vw;max-height:60vh;';
+		card.appendChild(image);
+	}
+	if (slate.text) {
+		var text = document.createElement('div');
+		text.textContent = slate.text;
+		card.appendChild(text);
+	}
+	if (slate.countdown_to) {
+		var countdown = document.createElement('div');
+		countdown.style.cssText = 'font-size:96px;font-variant-numeric:tabular-nums;';
+		card.appendChild(countdown);
+		var target = new Date(slate.countdown_to).getTime();
+		var pad = function (n) {
+			return (n < 10 ? '0' : '') + n;
+		};
+		var tick = function () {
+			var left = Math.max(0, Math.round((target - Date.now()) / 1000));
+			if (left === 0) {
+				countdown.textContent = 'Starting soon';
+				return;
+			}
+			var hours = Math.floor(left / 3600);
+			countdown.textContent = (hours > 0 ? hours + ':' : '') + pad(Math.floor(left % 3600 / 60)) + ':' + pad(left % 60);
+		};
+		tick();
+		window.__spoutbreezeSlateCountdown = setInterval(tick, 1000);
+	}
+	if (slate.html) {
+		var markup = document.createElement('div');
+		markup.innerHTML = slate.html;
+		card.appendChild(markup);
+	}
+}
+var mute = function () {
+	document.querySelectorAll('audio, video').forEach(function (media) {
+		if (media.dataset.spoutbreezeSlateMedia) {
+			return;
+		}
+		if (!media.muted) {
+			media.muted = true;
+			media.dataset.spoutbreezeMuted = '1';
+		}
+	});
+};
+mute();
+if (!window.__spoutbreezeSlateObserver) {
+	window.__spoutbreezeSlateObserver = new MutationObserver(mute);
+	window.__spoutbreezeSlateObserver.observe(document.documentElement, {childList: true, subtree: true});
+}
+`
+
+// hideSlateScript removes the slate, fading it out with arguments[0], and
+// unmutes what slateScript muted. A slate shown while the old one fades out
+// gets a card of its own.
+const hideSlateScript = `
+var card = document.getElementById('spoutbreeze-slate');
+if (card) {
+	card.id = 'spoutbreeze-slate-leaving';
+	clearInterval(window.__spoutbreezeSlateCountdown);
+	if (arguments[0]) {
+		card.style.opacity = '0';
+		setTimeout(function () {
+			card.remove();
+		}, 1000);
+	} else {
+		card.remove();
+	}
+}
+if (window.__spoutbreezeSlateObserver) {
+	window.__spoutbreezeSlateObserver.disconnect();
+	window.__spoutbreezeSlateObserver = null;
+}
+document.querySelectorAll('[data-spoutbreeze-muted]').forEach(function (media) {
+	media.muted = false;
+	delete media.dataset.spoutbreezeMuted;
+});
+`
+
+// navigateScript leaves the current page without waiting for the next one.
+const navigateScript = `
+window.__spoutbreezeNavigating = true;
+window.location.href = arguments[0];
+`
+
+// coverNewPageScript installs the slate as soon as the document navigated to
+// has a body, and reports whether it finished loading.
+const coverNewPageScript = `
+if (window.__spoutbreezeNavigating || !document.body) {
+	return false;
+}
+` + slateScript + `
+return document.readyState === 'complete';
+`
+
+func showSlate(driver selenium.WebDriver, slate models.Slate, fade bool) error {
+	_, err := driver.ExecuteScript(slateScript, []interface{}{slate, fade})
+	return err
+}
+
+func hideSlate(driver selenium.WebDriver, fade bool) error {
+	_, err := driver.ExecuteScript(hideSlateScript, []interface{}{fade})
+	return err
+}
+
+// waitForMeetingStart shows the starting slate until the meeting is running.
+// The slate then stays up as the cover of the join, see coverJoin.
+func waitForMeetingStart(driver selenium.WebDriver, session *Session) error {
+	slate := session.Request.StartingSlate
+	if slate == nil {
+		return nil
+	}
+	err := driver.Get("about:blank")
+	if err != nil {
+		return fmt.Errorf("failed to open blank page: %w", err)
+	}
+	err = showSlate(driver, *slate, false)
+	if err != nil {
+		return fmt.Errorf("failed to show the starting slate: %w", err)
+	}
+	session.setCoverSlate(slate)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	deadline := time.Now().Add(meetingStartTimeout)
+	if slate.CountdownTo != nil && slate.CountdownTo.After(time.Now()) {
+		deadline = slate.CountdownTo.Add(meetingStartTimeout)
+	}
+	session.AddEvent("starting_slate", "waiting for the meeting to start")
+	for {
+		running, err := IsMeetingRunning(client, session.Request.BBBHealthCheckURL)
+		if err != nil {
+			log.Printf("%v", err)
+		} else if running {
+			session.AddEvent("meeting_started", "meeting is running, joining")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &SessionError{Reason: models.ReasonMeetingNotStarted, Err: fmt.Errorf("meeting did not start within %s", meetingStartTimeout)}
+		}
+		time.Sleep(meetingStartPollInterval)
+	}
+}
+
+// navigateUnderSlate opens url and puts the slate back up as soon as the new
+// page exists, so the stream does not show the client loading.
+func navigateUnderSlate(driver selenium.WebDriver, url string, slate models.Slate) error {
+	_, err := driver.ExecuteScript(navigateScript, []interface{}{url})
+	if err != nil {
+		return fmt.Errorf("failed to navigate to %s: %w", url, err)
+	}
+	deadline := time.Now().Add(navigationTimeout)
+	for time.Now().Before(deadline) {
+		// Scripts fail while the old page unloads
+		result, err := driver.ExecuteScript(coverNewPageScript, []interface{}{slate, false})
+		if loaded, _ := result.(bool); err == nil && loaded {
+			return nil
+		}
+		time.Sleep(navigationPollInterval)
+	}
+	return errors.New("page did not load in time")
+}
+
+// coverJoin reinstalls the cover slate while the bot joins, in case the
+// client moved to another page.
+func coverJoin(driver selenium.WebDriver, session *Session) {
+	slate := session.coverSlate()
+	if slate == nil {
+		return
+	}
+	err := showSlate(driver, *slate, false)
+	if err != nil {
+		log.Printf("Warning: Failed to reinstall the slate: %v", err)
+	}
+}
+
+// revealMeeting fades the cover slate out once the bot is in the meeting
+// with its layout applied.
+func revealMeeting(driver selenium.WebDriver, session *Session) {
+	if session.coverSlate() == nil {
+		return
+	}
+	session.setCoverSlate(nil)
+	err := hideSlate(driver, true)
+	if err != nil {
+		log.Printf("Warning: Failed to take the starting slate down: %v", err)
+	}
+}
+
+// showEndedSlate fades the ended slate in and holds it on the stream. It
+// returns false when the request has none.
+func showEndedSlate(driver selenium.WebDriver, request *models.BroadcasterRequest) bool {
+	if request.EndedSlate == nil {
+		return false
+	}
+	err := showSlate(driver, *request.EndedSlate, true)
+	if err != nil {
+		log.Printf("Warning: Failed to show the ended slate: %v", err)
+	}
+	time.Sleep(slateFade + endedSlateHold(request))
+	return true
+}
+
+// endedSlateHold is how long the ended slate stays once it has faded in.
+func endedSlateHold(request *models.BroadcasterRequest) time.Duration {
+	seconds := defaultEndedSlateSeconds
+	if request.EndedSlateSeconds != nil {
+		seconds = *request.EndedSlateSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (s *Session) coverSlate() *models.Slate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cover
+}
+
+func (s *Session) setCoverSlate(slate *models.Slate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cover = slate
+}
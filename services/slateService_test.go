@@ -0,0 +1,186 @@
+package services_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/tebeka/selenium"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// fakeSlatePage is a browser that records the scripts run on it with their
+// arguments. Scripts fail with the errors in results and report whether the
+// page loaded from loaded, in turn, the last of each repeating.
+type fakeSlatePage struct {
+	selenium.WebDriver
+	results []error
+	loaded  []bool
+	scripts []string
+	args    [][]interface{}
+	visited []string
+}
+
+func (p *fakeSlatePage) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	p.scripts = append(p.scripts, script)
+	p.args = append(p.args, args)
+	var err error
+	if len(p.results) > 0 {
+		err = p.results[0]
+		if len(p.results) > 1 {
+			p.results = p.results[1:]
+		}
+	}
+	loaded := false
+	if len(p.loaded) > 0 {
+		loaded = p.loaded[0]
+		if len(p.loaded) > 1 {
+			p.loaded = p.loaded[1:]
+		}
+	}
+	return loaded, err
+}
+
+func (p *fakeSlatePage) Get(url string) error {
+	p.visited = append(p.visited, url)
+	return nil
+}
+
+var _ = Describe("Slate Service", func() {
+	slate := models.Slate{Text: "Lecture starts at 2pm"}
+
+	eventTypes := func(session *services.Session) []string {
+		var types []string
+		for _, event := range session.Snapshot().Events {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	BeforeEach(func() {
+		DeferCleanup(services.ShortenSlateTimings(10*time.Millisecond, 200*time.Millisecond))
+	})
+
+	Describe("the starting slate", func() {
+		var checks atomic.Int32
+		var runningAfter atomic.Int32
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			checks.Store(0)
+			runningAfter.Store(3)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				running := "false"
+				if checks.Add(1) >= runningAfter.Load() {
+					running = "true"
+				}
+				w.Write([]byte(`<response><returncode>SUCCESS</returncode><running>` + running + `</running></response>`))
+			}))
+			DeferCleanup(server.Close)
+		})
+
+		It("should hold the slate until the health check reports the meeting running", func() {
+			session := services.NewSession(&models.BroadcasterRequest{BBBHealthCheckURL: server.URL, StartingSlate: &slate})
+			page := &fakeSlatePage{}
+
+			Expect(services.WaitForMeetingStart(page, session)).To(Succeed())
+			Expect(checks.Load()).To(BeNumerically("==", 3))
+			Expect(page.visited).To(Equal([]string{"about:blank"}))
+			Expect(page.scripts).To(HaveLen(1))
+			Expect(page.args[0]).To(Equal([]interface{}{slate, false}))
+			Expect(eventTypes(session)).To(Equal([]string{"starting_slate", "meeting_started"}))
+
+			services.RevealMeeting(page, session)
+			Expect(page.scripts).To(HaveLen(2))
+			Expect(page.scripts[1]).To(ContainSubstring("spoutbreeze-slate-leaving"))
+			Expect(page.args[1]).To(Equal([]interface{}{true}))
+
+			// The slate is only taken down once
+			services.RevealMeeting(page, session)
+			Expect(page.scripts).To(HaveLen(2))
+		})
+
+		It("should fail when the meeting does not start by the deadline", func() {
+			runningAfter.Store(1 << 30)
+			session := services.NewSession(&models.BroadcasterRequest{BBBHealthCheckURL: server.URL, StartingSlate: &slate})
+
+			err := services.WaitForMeetingStart(&fakeSlatePage{}, session)
+			var sessionErr *services.SessionError
+			Expect(errors.As(err, &sessionErr)).To(BeTrue())
+			Expect(sessionErr.Reason).To(Equal(models.ReasonMeetingNotStarted))
+			Expect(checks.Load()).To(BeNumerically(">", 1))
+		})
+
+		It("should join right away without a starting slate", func() {
+			session := services.NewSession(&models.BroadcasterRequest{BBBHealthCheckURL: server.URL})
+			page := &fakeSlatePage{}
+
+			Expect(services.WaitForMeetingStart(page, session)).To(Succeed())
+			Expect(checks.Load()).To(BeZero())
+			Expect(page.scripts).To(BeEmpty())
+
+			services.RevealMeeting(page, session)
+			Expect(page.scripts).To(BeEmpty())
+		})
+	})
+
+	Describe("NavigateUnderSlate", func() {
+		It("should put the slate back up on the new page once it exists", func() {
+			page := &fakeSlatePage{
+				// The old page unloads, then the new one has no body, then it loaded
+				results: []error{nil, errors.New("script timeout"), nil},
+				loaded:  []bool{false, false, false, true},
+			}
+
+			Expect(services.NavigateUnderSlate(page, "https://bbb.example.com/join", slate)).To(Succeed())
+			Expect(page.scripts).To(HaveLen(4))
+			Expect(page.args[0]).To(Equal([]interface{}{"https://bbb.example.com/join"}))
+			for i, script := range page.scripts[1:] {
+				Expect(script).To(ContainSubstring("spoutbreeze-slate"))
+				Expect(page.args[i+1]).To(Equal([]interface{}{slate, false}))
+			}
+		})
+
+		It("should give up when the new page does not load", func() {
+			page := &fakeSlatePage{}
+
+			Expect(services.NavigateUnderSlate(page, "https://bbb.example.com/join", slate)).To(MatchError(ContainSubstring("did not load")))
+		})
+	})
+
+	Describe("the ended slate", func() {
+		It("should fade the ended slate in", func() {
+			hold := 0
+			page := &fakeSlatePage{}
+			request := &models.BroadcasterRequest{EndedSlate: &slate, EndedSlateSeconds: &hold}
+
+			Expect(services.ShowEndedSlate(page, request)).To(BeTrue())
+			Expect(page.scripts).To(HaveLen(1))
+			Expect(page.scripts[0]).To(ContainSubstring("spoutbreeze-slate"))
+			Expect(page.args[0]).To(Equal([]interface{}{slate, true}))
+		})
+
+		It("should leave the plain ended card to requests without one", func() {
+			page := &fakeSlatePage{}
+
+			Expect(services.ShowEndedSlate(page, &models.BroadcasterRequest{})).To(BeFalse())
+			Expect(page.scripts).To(BeEmpty())
+		})
+
+		DescribeTable("the hold",
+			func(seconds *int, expected time.Duration) {
+				Expect(services.EndedSlateHold(&models.BroadcasterRequest{EndedSlateSeconds: seconds})).To(Equal(expected))
+			},
+			Entry("defaults to 30 seconds", nil, 30*time.Second),
+			Entry("can be turned off", func() *int { hold := 0; return &hold }(), time.Duration(0)),
+			Entry("follows the request", func() *int { hold := 90; return &hold }(), 90*time.Second),
+		)
+	})
+})
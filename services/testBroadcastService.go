@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+const defaultTestDuration = 60 * time.Second
+
+// testPageHTML is the built-in test page: colour bars, a running clock and
+// a 1 kHz tone, so picture, motion and sound can all be checked on the
+// destination. The tone relies on the autoplay policy switch the bot's
+// Chrome is started with.
+const testPageHTML = `<!DOCTYPE html>
+<html>
+<head>
+<title>SpoutBreeze test stream</title>
+<style>
+html, body { margin: 0; height: 100%; overflow: hidden; background: #000; }
+canvas { display: block; width: 100vw; height: 100vh; }
+</style>
+</head>
+<body>
+<canvas id="spoutbreeze-test-pattern"></canvas>
+<script>
+(function () {
+	var canvas = document.getElementById("spoutbreeze-test-pattern");
+	var ctx = canvas.getContext("2d");
+	var bars = ["#c0c0c0", "#c0c000", "#00c0c0", "#00c000", "#c000c0", "#c00000", "#0000c0"];
+	var pad = function (n, width) { return ("000" + n).slice(-(width || 2)); };
+	var draw = function () {
+		canvas.width = window.innerWidth;
+		canvas.height = window.innerHeight;
+		var w = canvas.width, h = canvas.height, barWidth = w / bars.length;
+		bars.forEach(function (color, i) {
+			ctx.fillStyle = color;
+			ctx.fillRect(Math.floor(i * barWidth), 0, Math.ceil(barWidth), h * 0.7);
+		});
+		ctx.fillStyle = "#101010";
+		ctx.fillRect(0, h * 0.7, w, h * 0.3);
+		var now = new Date();
+		ctx.fillStyle = "#fff";
+		ctx.textAlign = "center";
+		ctx.textBaseline = "middle";
+		ctx.font = "bold " + Math.round(h * 0.09) + "px monospace";
+		ctx.fillText(pad(now.getHours()) + ":" + pad(now.getMinutes()) + ":" + pad(now.getSeconds()) + "." + pad(now.getMilliseconds(), 3), w / 2, h * 0.8);
+		ctx.font = Math.round(h * 0.035) + "px sans-serif";
+		ctx.fillText("SpoutBreeze test stream · {{SESSION_ID}}", w / 2, h * 0.92);
+		window.requestAnimationFrame(draw);
+	};
+	draw();
+
+	var audio = new (window.AudioContext || window.webkitAudioContext)();
+	var tone = audio.createOscillator();
+	var gain = audio.createGain();
+	tone.frequency.value = 1000;
+	gain.gain.value = 0.1;
+	tone.connect(gain);
+	gain.connect(audio.destination);
+	tone.start();
+	window.__spoutbreezeTestTone = audio;
+})();
+</script>
+</body>
+</html>
+`
+
+// testPageScript replaces the blank page with the test page. Writing the
+// document keeps the test page self-contained, the Moon browser does not need
+// to reach this service.
+const testPageScript = `
+document.open();
+document.write(arguments[0]);
+document.close();
+`
+
+// StartTestBroadcast registers a test session and streams the test page to
+// the destinations of the request for its duration.
+func StartTestBroadcast(request *models.TestBroadcastRequest) (*Session, error) {
+	broadcasterRequest := testBroadcasterRequest(request)
+	err := prepareDestinations(broadcasterRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := defaultTestDuration
+	if request.DurationSeconds > 0 {
+		duration = time.Duration(request.DurationSeconds) * time.Second
+	}
+
+	group := newSessionGroup(broadcasterRequest, models.SessionTypeTest)
+	for _, session := range group {
+		session.testDuration = duration
+		log.Printf("Starting test broadcast %s for %s", session.ID, duration)
+		go launchSeleniumScript(session)
+	}
+
+	return group[0], nil
+}
+
+// testBroadcasterRequest is the broadcast a test request stands for, without a
+// meeting.
+func testBroadcasterRequest(request *models.TestBroadcastRequest) *models.BroadcasterRequest {
+	return &models.BroadcasterRequest{
+		RTMPURL:          request.RTMPURL,
+		StreamKey:        request.StreamKey,
+		Platform:         request.Platform,
+		SRT:              request.SRT,
+		Destinations:     request.Destinations,
+		WHIPDestinations: request.WHIPDestinations,
+		Quality:          request.Quality,
+		Orientation:      request.Orientation,
+		Audio:            request.Audio,
+	}
+}
+
+// StreamTestSession shows the test page in the bot's browser and keeps it on
+// air until the test duration is over.
+func StreamTestSession(session *Session) error {
+	driver, err := startBotBrowser(session, session.relayKey)
+	if err != nil {
+		return err
+	}
+	defer fetchMoonVideos(session)
+	defer driver.Quit()
+	session.setDriver(driver)
+	defer session.setDriver(nil)
+	defer stopWHIP(session)
+	defer stopRelay(session)
+	defer session.stopRecording()
+
+	stopBrowserLogs := watchBrowserLogs(driver, session)
+	defer stopBrowserLogs()
+
+	err = showTestPage(driver, session.ID)
+	if err != nil {
+		return err
+	}
+	startWHIP(driver, session)
+
+	session.AddEvent("browser_started", "Moon session "+driver.SessionID())
+	session.SetState(models.SessionStateLive, "")
+
+	runTestBroadcast(driver, session, session.testDuration)
+	return nil
+}
+
+func showTestPage(driver selenium.WebDriver, sessionID string) error {
+	err := driver.Get("about:blank")
+	if err != nil {
+		return fmt.Errorf("failed to open blank page: %w", err)
+	}
+	page := strings.ReplaceAll(testPageHTML, "{{SESSION_ID}}", sessionID)
+	_, err = driver.ExecuteScript(testPageScript, []interface{}{page})
+	if err != nil {
+		return fmt.Errorf("failed to show test page: %w", err)
+	}
+	return nil
+}
+
+// runTestBroadcast follows the destination statuses until the duration is
+// over, then ends the session. The statuses are read once more at the end so
+// the session reports how each destination did.
+func runTestBroadcast(driver selenium.WebDriver, session *Session, duration time.Duration) {
+	deadline := time.Now().Add(duration)
+	var lastStatusCheck time.Time
+	for time.Now().Before(deadline) {
+		checkWHIP(driver, session)
+		if time.Since(lastStatusCheck) >= healthCheckInterval {
+			lastStatusCheck = time.Now()
+			refreshDestinationStatuses(session)
+		}
+		time.Sleep(meetingScreenPollInterval)
+	}
+
+	refreshDestinationStatuses(session)
+	session.AddEvent("test_completed", fmt.Sprintf("test stream ran for %s", duration))
+	session.SetState(models.SessionStateEnded, models.ReasonTestCompleted)
+}
@@ -0,0 +1,42 @@
+package services_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+var _ = Describe("Test Broadcast Service", func() {
+	It("should register a test session for the destinations", func() {
+		request := services.TestBroadcasterRequest(&models.TestBroadcastRequest{
+			Platform:  models.PlatformYouTube,
+			StreamKey: "abcd-efgh-ijkl-mnop",
+		})
+		Expect(services.PrepareDestinations(request)).To(Succeed())
+
+		session := services.NewSessionGroup(request, models.SessionTypeTest)[0]
+		Expect(session.Type).To(Equal(models.SessionTypeTest))
+
+		registered, ok := services.GetSession(session.ID)
+		Expect(ok).To(BeTrue())
+		snapshot := registered.Snapshot()
+		Expect(snapshot.Type).To(Equal(models.SessionTypeTest))
+		Expect(snapshot.Destinations).To(HaveLen(1))
+		Expect(snapshot.Destinations[0].URL).To(Equal("rtmp://a.rtmp.youtube.com/live2"))
+	})
+
+	It("should check stream keys like a real broadcast", func() {
+		err := services.PrepareDestinations(services.TestBroadcasterRequest(&models.TestBroadcastRequest{
+			RTMPURL:   "rtmp://live.twitch.tv/app",
+			StreamKey: "abcd-efgh-ijkl-mnop",
+		}))
+		Expect(err).To(MatchError(services.ErrInvalidRequest))
+	})
+
+	It("should keep meeting broadcasts typed as broadcasts", func() {
+		session := services.NewSession(&models.BroadcasterRequest{RTMPURL: "rtmp://streaming.example.com/live", StreamKey: "stream-123"})
+		Expect(session.Snapshot().Type).To(Equal(models.SessionTypeBroadcast))
+	})
+})
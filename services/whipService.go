@@ -0,0 +1,514 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"spoutbreeze/models"
+)
+
+// whipCaptureArgs let the page capture its own tab, audio included, without
+// the share picker.
+var whipCaptureArgs = []string{"--auto-accept-this-tab-capture"}
+
+const (
+	whipScriptTimeout      = 20 * time.Second
+	whipResultPollInterval = 250 * time.Millisecond
+)
+
+// Backoff of the republishing of a WHIP destination that failed, doubled on
+// each failed attempt. Variables so that tests can shorten them.
+var (
+	whipRetryMinBackoff = 5 * time.Second
+	whipRetryMaxBackoff = 2 * time.Minute
+)
+
+var whipClient = &http.Client{Timeout: 10 * time.Second}
+
+// whipCaptureScript installs a capture button. getDisplayMedia needs a user
+// gesture, so the button is clicked through WebDriver rather than from script.
+// It sits in the top-left pixel above the overlays so that the click lands.
+const whipCaptureScript = `
+if (!document.getElementById("spoutbreeze-whip-capture")) {
+	var button = document.createElement("button");
+	button.id = "spoutbreeze-whip-capture";
+	button.style.cssText = "position:fixed;top:0;left:0;width:1px;height:1px;padding:0;border:0;opacity:0.01;z-index:2147483647;";
+	button.addEventListener("click", function () {
+		window.__spoutbreezeWhipError = "";
+		navigator.mediaDevices.getDisplayMedia({
+			video: { frameRate: 30 },
+			audio: true,
+			preferCurrentTab: true,
+			selfBrowserSurface: "include",
+			systemAudio: "include"
+		}).then(function (stream) {
+			window.__spoutbreezeWhipStream = stream;
+		}).catch(function (err) {
+			window.__spoutbreezeWhipError = String(err);
+		});
+	});
+	document.body.appendChild(button);
+}
+`
+
+// whipOfferScript starts creating a send-only peer connection for one
+// destination. Its offer is left for whipResultScript once ICE gathering is
+// complete, as WHIP does not require trickle ICE. The scripts return at once
+// so that the negotiation does not hold up other WebDriver commands.
+const whipOfferScript = `
+var label = arguments[0];
+window.__spoutbreezeWhipResults = window.__spoutbreezeWhipResults || {};
+delete window.__spoutbreezeWhipResults[label];
+var done = function (result) {
+	window.__spoutbreezeWhipResults[label] = result;
+};
+var started = Date.now();
+var waitForStream = function () {
+	if (window.__spoutbreezeWhipStream) {
+		return createOffer(window.__spoutbreezeWhipStream);
+	}
+	if (window.__spoutbreezeWhipError || Date.now() - started > 10000) {
+		return done(JSON.stringify({ error: window.__spoutbreezeWhipError || "tab capture did not start" }));
+	}
+	setTimeout(waitForStream, 250);
+};
+var createOffer = function (stream) {
+	window.__spoutbreezeWhip = window.__spoutbreezeWhip || {};
+	if (window.__spoutbreezeWhip[label]) {
+		window.__spoutbreezeWhip[label].close();
+	}
+	var pc = new RTCPeerConnection({ bundlePolicy: "max-bundle" });
+	window.__spoutbreezeWhip[label] = pc;
+	stream.getTracks().forEach(function (track) {
+		pc.addTransceiver(track, { direction: "sendonly", streams: [stream] });
+	});
+	pc.createOffer().then(function (offer) {
+		return pc.setLocalDescription(offer);
+	}).then(function () {
+		return new Promise(function (resolve) {
+			if (pc.iceGatheringState === "complete") {
+				return resolve();
+			}
+			pc.addEventListener("icegatheringstatechange", function () {
+				if (pc.iceGatheringState === "complete") {
+					resolve();
+				}
+			});
+			setTimeout(resolve, 5000);
+		});
+	}).then(function () {
+		done(JSON.stringify({ offer: pc.localDescription.sdp }));
+	}).catch(function (err) {
+		done(JSON.stringify({ error: String(err) }));
+	});
+};
+waitForStream();
+`
+
+// whipAnswerScript starts applying the WHIP answer, and leaves "" or the
+// error for whipResultScript.
+const whipAnswerScript = `
+var label = arguments[0];
+window.__spoutbreezeWhipResults = window.__spoutbreezeWhipResults || {};
+delete window.__spoutbreezeWhipResults[label];
+var done = function (result) {
+	window.__spoutbreezeWhipResults[label] = result;
+};
+var pc = (window.__spoutbreezeWhip || {})[label];
+if (!pc) {
+	return done("peer connection not found");
+}
+pc.setRemoteDescription({ type: "answer", sdp: arguments[1] }).then(function () {
+	done("");
+}).catch(function (err) {
+	done(String(err));
+});
+`
+
+// whipResultScript takes the result a WHIP script left for a destination,
+// null while it is pending.
+const whipResultScript = `
+var results = window.__spoutbreezeWhipResults || {};
+var result = results[arguments[0]];
+delete results[arguments[0]];
+return result === undefined ? null : result;
+`
+
+const whipStateScript = `
+var states = {};
+var connections = window.__spoutbreezeWhip || {};
+Object.keys(connections).forEach(function (label) {
+	states[label] = connections[label].connectionState;
+});
+return JSON.stringify(states);
+`
+
+// WHIPSession is a publishing session created on a WHIP server. Deleting its
+// resource URL ends it.
+type WHIPSession struct {
+	Label       string
+	ResourceURL string
+	Answer      string
+	bearerToken string
+}
+
+// PublishWHIP posts an SDP offer to a WHIP endpoint and returns the session
+// the server created for it.
+func PublishWHIP(client *http.Client, destination models.WHIPDestination, offer string) (*WHIPSession, error) {
+	req, err := http.NewRequest(http.MethodPost, destination.EndpointURL, strings.NewReader(offer))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WHIP endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/sdp")
+	if destination.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+destination.BearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error posting WHIP offer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading WHIP answer: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("WHIP endpoint answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return nil, errors.New("WHIP endpoint did not return a session location")
+	}
+	// The location may be relative to the endpoint
+	resourceURL, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WHIP session location %q: %w", location, err)
+	}
+
+	return &WHIPSession{
+		Label:       destination.Label,
+		ResourceURL: resourceURL.String(),
+		Answer:      string(body),
+		bearerToken: destination.BearerToken,
+	}, nil
+}
+
+// Teardown deletes the session on the WHIP server.
+func (w *WHIPSession) Teardown(client *http.Client) error {
+	req, err := http.NewRequest(http.MethodDelete, w.ResourceURL, nil)
+	if err != nil {
+		return err
+	}
+	if w.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.bearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error deleting WHIP session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// A session the server already dropped counts as torn down
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("WHIP server answered %s to the teardown", resp.Status)
+	}
+	return nil
+}
+
+// startWHIP publishes the captured tab to every WHIP destination. It runs
+// after each join, as reloading the page drops the peer connections.
+// Sessions left over from before are torn down first. A destination that
+// fails is retried by checkWHIP.
+func startWHIP(driver selenium.WebDriver, session *Session) {
+	if len(session.Request.WHIPDestinations) == 0 {
+		return
+	}
+	stopWHIP(session)
+
+	err := startTabCapture(driver)
+	if err != nil {
+		log.Printf("Warning: Failed to start tab capture for WHIP: %v", err)
+		for _, destination := range session.Request.WHIPDestinations {
+			session.setDestinationStatus(destination.Label, models.DestinationStateFailed, err.Error())
+			session.retryWHIPLater(destination.Label)
+		}
+		return
+	}
+	for _, destination := range session.Request.WHIPDestinations {
+		publishWHIPDestination(driver, session, destination)
+	}
+}
+
+func startTabCapture(driver selenium.WebDriver) error {
+	_, err := driver.ExecuteScript(whipCaptureScript, nil)
+	if err != nil {
+		return fmt.Errorf("failed to install capture button: %w", err)
+	}
+	button, err := driver.FindElement(selenium.ByCSSSelector, "#spoutbreeze-whip-capture")
+	if err != nil {
+		return fmt.Errorf("capture button not found: %w", err)
+	}
+	return button.Click()
+}
+
+// publishWHIPDestination publishes to a destination, and schedules another
+// attempt when it fails.
+func publishWHIPDestination(driver selenium.WebDriver, session *Session, destination models.WHIPDestination) {
+	whipSession, err := negotiateWHIP(driver, destination)
+	if err != nil {
+		log.Printf("Warning: Failed to publish to WHIP destination %s: %v", destination.Label, err)
+		session.setDestinationStatus(destination.Label, models.DestinationStateFailed, err.Error())
+		session.retryWHIPLater(destination.Label)
+		return
+	}
+	session.setWHIPSession(destination.Label, whipSession)
+	session.clearWHIPRetry(destination.Label)
+	session.AddEvent("whip_published", destination.Label+" "+whipSession.ResourceURL)
+}
+
+// republishWHIP replaces the WHIP session of a destination that failed. It
+// runs next to the meeting watch, as a negotiation takes seconds.
+func republishWHIP(driver selenium.WebDriver, session *Session, destination models.WHIPDestination, retry *whipRetry) {
+	if whipSession := session.takeWHIPSession(destination.Label); whipSession != nil {
+		teardownWHIPSession(whipSession)
+	}
+	whipSession, err := negotiateWHIP(driver, destination)
+	if !session.finishWHIPRetry(destination.Label, retry, err == nil) {
+		// The broadcast stopped its WHIP sessions meanwhile
+		if whipSession != nil {
+			teardownWHIPSession(whipSession)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to republish to WHIP destination %s: %v", destination.Label, err)
+		session.setDestinationStatus(destination.Label, models.DestinationStateFailed, err.Error())
+		return
+	}
+	session.setWHIPSession(destination.Label, whipSession)
+	session.AddEvent("whip_published", destination.Label+" "+whipSession.ResourceURL)
+}
+
+func negotiateWHIP(driver selenium.WebDriver, destination models.WHIPDestination) (*WHIPSession, error) {
+	_, err := driver.ExecuteScript(whipOfferScript, []interface{}{destination.Label})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebRTC offer: %w", err)
+	}
+	raw, err := awaitWHIPResult(driver, destination.Label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebRTC offer: %w", err)
+	}
+	var offer struct {
+		Offer string `json:"offer"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(raw), &offer); err != nil {
+		return nil, fmt.Errorf("failed to parse WebRTC offer: %w", err)
+	}
+	if offer.Error != "" {
+		return nil, fmt.Errorf("failed to create WebRTC offer: %s", offer.Error)
+	}
+
+	whipSession, err := PublishWHIP(whipClient, destination, offer.Offer)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = driver.ExecuteScript(whipAnswerScript, []interface{}{destination.Label, whipSession.Answer})
+	if err == nil {
+		var message string
+		message, err = awaitWHIPResult(driver, destination.Label)
+		if err == nil && message != "" {
+			err = errors.New(message)
+		}
+	}
+	if err != nil {
+		teardownWHIPSession(whipSession)
+		return nil, fmt.Errorf("failed to apply WHIP answer: %w", err)
+	}
+	return whipSession, nil
+}
+
+// awaitWHIPResult polls for the result a WHIP script left for a destination.
+func awaitWHIPResult(driver selenium.WebDriver, label string) (string, error) {
+	deadline := time.Now().Add(whipScriptTimeout)
+	for time.Now().Before(deadline) {
+		result, err := driver.ExecuteScript(whipResultScript, []interface{}{label})
+		if err != nil {
+			return "", err
+		}
+		if raw, ok := result.(string); ok {
+			return raw, nil
+		}
+		time.Sleep(whipResultPollInterval)
+	}
+	return "", fmt.Errorf("no result within %s", whipScriptTimeout)
+}
+
+// checkWHIP follows the peer connection states on each monitor tick. A
+// destination whose connection failed, or that could not be published, is
+// published again in the background once its backoff is over.
+func checkWHIP(driver selenium.WebDriver, session *Session) {
+	if len(session.Request.WHIPDestinations) == 0 {
+		return
+	}
+	result, err := driver.ExecuteScript(whipStateScript, nil)
+	if err != nil {
+		log.Printf("Warning: Failed to read WHIP connection states: %v", err)
+		return
+	}
+	raw, _ := result.(string)
+	states := map[string]string{}
+	if err := json.Unmarshal([]byte(raw), &states); err != nil {
+		log.Printf("Warning: Failed to parse WHIP connection states: %v", err)
+		return
+	}
+
+	for _, destination := range session.Request.WHIPDestinations {
+		switch state := states[destination.Label]; state {
+		case "connected":
+			session.setDestinationStatus(destination.Label, models.DestinationStateLive, "")
+		case "failed", "closed":
+			session.setDestinationStatus(destination.Label, models.DestinationStateFailed, "WebRTC connection "+state)
+			session.retryWHIPSoon(destination.Label)
+		}
+		if retry, attempt := session.startWHIPRetry(destination.Label); retry != nil {
+			session.AddEvent("whip_retry", fmt.Sprintf("%s, attempt %d", destination.Label, attempt))
+			go republishWHIP(driver, session, destination, retry)
+		}
+	}
+}
+
+// stopWHIP ends every WHIP session of the broadcast on its server.
+func stopWHIP(session *Session) {
+	session.clearWHIPRetries()
+	for _, destination := range session.Request.WHIPDestinations {
+		if whipSession := session.takeWHIPSession(destination.Label); whipSession != nil {
+			teardownWHIPSession(whipSession)
+			session.AddEvent("whip_stopped", destination.Label)
+		}
+	}
+}
+
+func teardownWHIPSession(whipSession *WHIPSession) {
+	err := whipSession.Teardown(whipClient)
+	if err != nil {
+		log.Printf("Warning: Failed to tear down WHIP session %s: %v", whipSession.ResourceURL, err)
+	}
+}
+
+func (s *Session) setWHIPSession(label string, whipSession *WHIPSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.whipSessions == nil {
+		s.whipSessions = map[string]*WHIPSession{}
+	}
+	s.whipSessions[label] = whipSession
+}
+
+// whipRetry is the republishing schedule of a WHIP destination.
+type whipRetry struct {
+	attempts int
+	due      time.Time
+	running  bool
+}
+
+func whipRetryBackoff(attempts int) time.Duration {
+	backoff := whipRetryMinBackoff
+	for i := 1; i < attempts && backoff < whipRetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, whipRetryMaxBackoff)
+}
+
+// retryWHIPLater schedules the next attempt after a failed one.
+func (s *Session) retryWHIPLater(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.whipRetries == nil {
+		s.whipRetries = map[string]*whipRetry{}
+	}
+	retry := s.whipRetries[label]
+	if retry == nil {
+		retry = &whipRetry{}
+		s.whipRetries[label] = retry
+	}
+	retry.attempts++
+	retry.due = time.Now().Add(whipRetryBackoff(retry.attempts))
+	retry.running = false
+}
+
+// retryWHIPSoon schedules an attempt for a connection that failed, unless
+// one is already scheduled.
+func (s *Session) retryWHIPSoon(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.whipRetries == nil {
+		s.whipRetries = map[string]*whipRetry{}
+	}
+	if s.whipRetries[label] == nil {
+		s.whipRetries[label] = &whipRetry{due: time.Now()}
+	}
+}
+
+// startWHIPRetry claims a scheduled attempt that is due, and returns it
+// with its number.
+func (s *Session) startWHIPRetry(label string) (*whipRetry, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	retry := s.whipRetries[label]
+	if retry == nil || retry.running || time.Now().Before(retry.due) {
+		return nil, 0
+	}
+	retry.running = true
+	return retry, retry.attempts + 1
+}
+
+// finishWHIPRetry records the outcome of an attempt. It returns false when
+// the retries were cleared while it ran.
+func (s *Session) finishWHIPRetry(label string, retry *whipRetry, published bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.whipRetries[label] != retry {
+		return false
+	}
+	if published {
+		delete(s.whipRetries, label)
+		return true
+	}
+	retry.attempts++
+	retry.due = time.Now().Add(whipRetryBackoff(retry.attempts))
+	retry.running = false
+	return true
+}
+
+func (s *Session) clearWHIPRetry(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.whipRetries, label)
+}
+
+func (s *Session) clearWHIPRetries() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.whipRetries = nil
+}
+
+func (s *Session) takeWHIPSession(label string) *WHIPSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	whipSession := s.whipSessions[label]
+	delete(s.whipSessions, label)
+	return whipSession
+}
@@ -0,0 +1,273 @@
+package services_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tebeka/selenium"
+
+	"spoutbreeze/models"
+	"spoutbreeze/services"
+)
+
+// whipStandIn is a minimal WHIP server: it answers offers with a fixed SDP
+// and records the requests it receives.
+type whipStandIn struct {
+	offers        []string
+	authorization []string
+	deleted       []string
+}
+
+func (w *whipStandIn) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w.authorization = append(w.authorization, req.Header.Get("Authorization"))
+	if req.Header.Get("Authorization") != "Bearer secret-token" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case req.Method == http.MethodPost && req.URL.Path == "/whip/endpoint":
+		if req.Header.Get("Content-Type") != "application/sdp" {
+			rw.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		offer, _ := io.ReadAll(req.Body)
+		w.offers = append(w.offers, string(offer))
+		rw.Header().Set("Location", "/whip/resource/1")
+		rw.Header().Set("Content-Type", "application/sdp")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte("v=0\r\no=- answer\r\n"))
+	case req.Method == http.MethodDelete && req.URL.Path == "/whip/resource/1":
+		w.deleted = append(w.deleted, req.URL.Path)
+		rw.WriteHeader(http.StatusOK)
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// fakeWHIPPage is a browser that publishes over WebRTC: it answers the WHIP
+// scripts at once and reports the peer connection states it is given.
+type fakeWHIPPage struct {
+	selenium.WebDriver
+	mu      sync.Mutex
+	results map[string]string
+	states  map[string]string
+}
+
+type fakeButton struct {
+	selenium.WebElement
+}
+
+func (b *fakeButton) Click() error {
+	return nil
+}
+
+func (p *fakeWHIPPage) FindElement(by string, value string) (selenium.WebElement, error) {
+	return &fakeButton{}, nil
+}
+
+func (p *fakeWHIPPage) setState(label string, state string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[label] = state
+}
+
+func (p *fakeWHIPPage) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case strings.Contains(script, "result === undefined"):
+		label := args[0].(string)
+		result, ok := p.results[label]
+		if !ok {
+			return nil, nil
+		}
+		delete(p.results, label)
+		return result, nil
+	case strings.Contains(script, "connectionState"):
+		states, _ := json.Marshal(p.states)
+		return string(states), nil
+	case strings.Contains(script, "setRemoteDescription"):
+		p.results[args[0].(string)] = ""
+	case strings.Contains(script, "createOffer"):
+		p.results[args[0].(string)] = `{"offer":"v=0\r\no=- offer\r\n"}`
+	}
+	return nil, nil
+}
+
+var _ = Describe("WHIP Service", func() {
+	var (
+		standIn *whipStandIn
+		server  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		standIn = &whipStandIn{}
+		server = httptest.NewServer(standIn)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should publish an offer and tear the session down", func() {
+		destination := models.WHIPDestination{EndpointURL: server.URL + "/whip/endpoint", BearerToken: "secret-token", Label: "partner"}
+
+		whipSession, err := services.PublishWHIP(server.Client(), destination, "v=0\r\no=- offer\r\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(standIn.offers).To(Equal([]string{"v=0\r\no=- offer\r\n"}))
+		Expect(whipSession.Label).To(Equal("partner"))
+		Expect(whipSession.Answer).To(Equal("v=0\r\no=- answer\r\n"))
+		Expect(whipSession.ResourceURL).To(Equal(server.URL + "/whip/resource/1"))
+
+		Expect(whipSession.Teardown(server.Client())).To(Succeed())
+		Expect(standIn.deleted).To(Equal([]string{"/whip/resource/1"}))
+		Expect(standIn.authorization).To(HaveEach("Bearer secret-token"))
+	})
+
+	It("should report a rejected token", func() {
+		destination := models.WHIPDestination{EndpointURL: server.URL + "/whip/endpoint", BearerToken: "wrong", Label: "partner"}
+
+		_, err := services.PublishWHIP(server.Client(), destination, "v=0\r\n")
+		Expect(err).To(MatchError(ContainSubstring("401")))
+	})
+
+	It("should fail when the endpoint does not create a session", func() {
+		destination := models.WHIPDestination{EndpointURL: server.URL + "/whip/missing", BearerToken: "secret-token", Label: "partner"}
+
+		_, err := services.PublishWHIP(server.Client(), destination, "v=0\r\n")
+		Expect(err).To(MatchError(ContainSubstring("404")))
+	})
+
+	It("should track WHIP destinations next to RTMP ones", func() {
+		session := services.NewSession(&models.BroadcasterRequest{
+			WHIPDestinations: []models.WHIPDestination{{EndpointURL: server.URL + "/whip/endpoint", Label: "partner"}},
+		})
+
+		destinations := session.Snapshot().Destinations
+		Expect(destinations).To(HaveLen(1))
+		Expect(destinations[0].Protocol).To(Equal(models.ProtocolWHIP))
+		Expect(destinations[0].State).To(Equal(models.DestinationStatePending))
+	})
+
+	It("should reject a WHIP label already used by an RTMP destination", func() {
+		err := services.PrepareDestinations(&models.BroadcasterRequest{
+			Destinations:     []models.Destination{{URL: "rtmp://streaming.example.com/live", Key: "one", Label: "partner"}},
+			WHIPDestinations: []models.WHIPDestination{{EndpointURL: server.URL + "/whip/endpoint", Label: "partner"}},
+		})
+		Expect(err).To(MatchError(services.ErrInvalidRequest))
+	})
+
+	Describe("republishing", func() {
+		var (
+			posts   atomic.Int32
+			deletes atomic.Int32
+			fail    atomic.Bool
+			release chan struct{}
+			page    *fakeWHIPPage
+			session *services.Session
+		)
+
+		BeforeEach(func() {
+			DeferCleanup(services.ShortenWHIPBackoff(200 * time.Millisecond))
+			posts.Store(0)
+			deletes.Store(0)
+			fail.Store(false)
+			release = make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.Method == http.MethodDelete {
+					deletes.Add(1)
+					return
+				}
+				if posts.Add(1) > 1 {
+					<-release
+				}
+				if fail.Load() {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				rw.Header().Set("Location", "/whip/resource/1")
+				rw.WriteHeader(http.StatusCreated)
+				rw.Write([]byte("v=0\r\no=- answer\r\n"))
+			}))
+			DeferCleanup(server.Close)
+			DeferCleanup(func() {
+				select {
+				case <-release:
+				default:
+					close(release)
+				}
+			})
+			page = &fakeWHIPPage{results: map[string]string{}, states: map[string]string{}}
+			session = services.NewSession(&models.BroadcasterRequest{
+				WHIPDestinations: []models.WHIPDestination{{EndpointURL: server.URL + "/whip/endpoint", Label: "partner"}},
+			})
+		})
+
+		eventCount := func(eventType string) func() int {
+			return func() int {
+				count := 0
+				for _, event := range session.Snapshot().Events {
+					if event.Type == eventType {
+						count++
+					}
+				}
+				return count
+			}
+		}
+
+		It("should retry a destination whose first publish failed once its backoff is over", func() {
+			fail.Store(true)
+			services.StartWHIP(page, session)
+			Expect(posts.Load()).To(Equal(int32(1)))
+
+			services.CheckWHIP(page, session)
+			Expect(eventCount("whip_retry")()).To(Equal(0))
+
+			time.Sleep(250 * time.Millisecond)
+			fail.Store(false)
+			start := time.Now()
+			services.CheckWHIP(page, session)
+			// The negotiation waits on the endpoint, the monitor tick does not
+			Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+			Eventually(posts.Load).Should(Equal(int32(2)))
+			services.CheckWHIP(page, session)
+			Expect(eventCount("whip_retry")()).To(Equal(1))
+
+			close(release)
+			Eventually(eventCount("whip_published")).Should(Equal(1))
+		})
+
+		It("should republish a failed connection and back off while it keeps failing", func() {
+			close(release)
+			services.StartWHIP(page, session)
+			Expect(eventCount("whip_published")()).To(Equal(1))
+
+			fail.Store(true)
+			page.setState("partner", "failed")
+			services.CheckWHIP(page, session)
+			Eventually(posts.Load).Should(Equal(int32(2)))
+			Eventually(deletes.Load).Should(Equal(int32(1)))
+
+			// The failed attempt waits out its backoff before the next one
+			Consistently(func() int {
+				services.CheckWHIP(page, session)
+				return eventCount("whip_retry")()
+			}, 150*time.Millisecond, 20*time.Millisecond).Should(Equal(1))
+			fail.Store(false)
+			Eventually(func() int {
+				services.CheckWHIP(page, session)
+				return eventCount("whip_retry")()
+			}, time.Second, 20*time.Millisecond).Should(Equal(2))
+			Eventually(eventCount("whip_published")).Should(Equal(2))
+			Expect(posts.Load()).To(Equal(int32(3)))
+		})
+	})
+})